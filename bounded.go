@@ -0,0 +1,136 @@
+package bytespool
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// BoundedPoolFactory returns a factory producing the BoundedPool of each size class.
+// A produced pool keeps at most maxCount idle bytes,
+// and at most maxBytes of the idle capacity if maxBytes is greater than 0.
+// If maxCount is 0, the pool is limited by maxBytes only.
+func BoundedPoolFactory(maxCount, maxBytes int) SizedBytesPoolFactory {
+	if maxCount < 0 || maxBytes < 0 || (maxCount == 0 && maxBytes == 0) {
+		panic(fmt.Sprintf("invalid bound, max count: %d, max bytes: %d", maxCount, maxBytes))
+	}
+
+	return func(size int) Pool {
+		count := maxCount
+		if maxBytes > 0 {
+			if byBytes := maxBytes / size; count == 0 || byBytes < count {
+				count = byBytes
+			}
+		}
+		return NewBoundedPool(count)
+	}
+}
+
+// boundedSlot is a slot of BoundedPool.
+// For the position p of the slot, the sequence is 2p if the slot is empty,
+// or 2p+1 if the slot is filled.
+type boundedSlot struct {
+	sequence atomic.Uint64
+	item     interface{}
+}
+
+// BoundedPool is a lock-free pool that keeps at most a fixed number of items.
+// Unlike sync.Pool, the items survive garbage collections.
+// Get returns nil if the pool is empty, and Put drops the item if the pool is full.
+type BoundedPool struct {
+	slots []boundedSlot
+
+	// the positions are padded to cache lines, so Get and Put do not share one.
+	_    [56]byte
+	head atomic.Uint64
+	_    [56]byte
+	tail atomic.Uint64
+	_    [56]byte
+}
+
+// NewBoundedPool creates a BoundedPool that keeps at most maxCount items.
+func NewBoundedPool(maxCount int) *BoundedPool {
+	if maxCount < 0 {
+		panic("max count must be greater than or equal to 0")
+	}
+
+	pool := &BoundedPool{
+		slots: make([]boundedSlot, maxCount),
+	}
+	for idx := range pool.slots {
+		pool.slots[idx].sequence.Store(uint64(idx) * 2)
+	}
+	return pool
+}
+
+// Get returns a idle item, or nil if the pool is empty.
+func (pool *BoundedPool) Get() interface{} {
+	if len(pool.slots) == 0 {
+		return nil
+	}
+
+	length := uint64(len(pool.slots))
+	position := pool.head.Load()
+	for {
+		slot := &pool.slots[position%length]
+		sequence := slot.sequence.Load()
+		switch diff := int64(sequence - (position*2 + 1)); {
+		case diff == 0:
+			if pool.head.CompareAndSwap(position, position+1) {
+				x := slot.item
+				slot.item = nil
+				slot.sequence.Store((position + length) * 2)
+				return x
+			}
+			position = pool.head.Load()
+		case diff < 0:
+			// empty
+			return nil
+		default:
+			position = pool.head.Load()
+		}
+	}
+}
+
+// Put keeps the item, or drops it if the pool is full.
+func (pool *BoundedPool) Put(x interface{}) {
+	if len(pool.slots) == 0 {
+		return
+	}
+
+	length := uint64(len(pool.slots))
+	position := pool.tail.Load()
+	for {
+		slot := &pool.slots[position%length]
+		sequence := slot.sequence.Load()
+		switch diff := int64(sequence - position*2); {
+		case diff == 0:
+			if pool.tail.CompareAndSwap(position, position+1) {
+				slot.item = x
+				slot.sequence.Store(position*2 + 1)
+				return
+			}
+			position = pool.tail.Load()
+		case diff < 0:
+			// full
+			return
+		default:
+			position = pool.tail.Load()
+		}
+	}
+}
+
+// Len returns the number of the idle items.
+// It is approximate while the pool is used concurrently.
+func (pool *BoundedPool) Len() int {
+	head := pool.head.Load()
+	tail := pool.tail.Load()
+	if tail <= head {
+		return 0
+	}
+	return int(tail - head)
+}
+
+// Cap returns the maximum number of the idle items.
+func (pool *BoundedPool) Cap() int {
+	return len(pool.slots)
+}
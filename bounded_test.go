@@ -0,0 +1,79 @@
+package bytespool
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestBoundedPool(t *testing.T) {
+	pool := NewBoundedPool(2)
+	if x := pool.Get(); x != nil {
+		t.Fatalf("empty pool should return nil, have: %v", x)
+		return
+	}
+
+	pool.Put(1)
+	pool.Put(2)
+	pool.Put(3) // full, dropped
+	if pool.Len() != 2 {
+		t.Fatalf("length error, want: %d, have: %d", 2, pool.Len())
+		return
+	}
+
+	// survive garbage collections
+	runtime.GC()
+	runtime.GC()
+
+	for _, want := range []int{1, 2} {
+		if x := pool.Get(); x != want {
+			t.Fatalf("item error, want: %d, have: %v", want, x)
+			return
+		}
+	}
+	if x := pool.Get(); x != nil {
+		t.Fatalf("empty pool should return nil, have: %v", x)
+	}
+}
+
+func TestBoundedPoolConcurrency(t *testing.T) {
+	pool := NewBoundedPool(64)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				pool.Put(i*10000 + j)
+				pool.Get()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if pool.Len() > pool.Cap() {
+		t.Fatalf("length error, want: <= %d, have: %d", pool.Cap(), pool.Len())
+	}
+}
+
+func TestBoundedPoolFactory(t *testing.T) {
+	factory := BoundedPoolFactory(10, 1024)
+	if capacity := factory(512).(*BoundedPool).Cap(); capacity != 2 {
+		t.Fatalf("capacity error, want: %d, have: %d", 2, capacity)
+		return
+	}
+	if capacity := factory(16).(*BoundedPool).Cap(); capacity != 10 {
+		t.Fatalf("capacity error, want: %d, have: %d", 10, capacity)
+		return
+	}
+
+	pool := BytesPool{SizedPoolFactory: factory}
+	bytes := pool.Get(100)
+	pool.Put(bytes)
+	runtime.GC()
+	pool.Get(100)
+	if news := pool.Stats().Classes[pool.getIndex(100)].News; news != 1 {
+		t.Fatalf("news error, want: %d, have: %d", 1, news)
+	}
+}
@@ -0,0 +1,93 @@
+package bytespool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBudgetExceeded means the outstanding bytes of the pool reached MaxOutstandingBytes.
+var ErrBudgetExceeded = errors.New("bytespool: outstanding bytes budget exceeded")
+
+// BudgetMode decides what BytesPool.Get does if MaxOutstandingBytes is reached.
+type BudgetMode int
+
+const (
+	// BudgetFallback allocates the bytes without pooling and without counting them.
+	BudgetFallback BudgetMode = iota
+
+	// BudgetFail fails with ErrBudgetExceeded.
+	BudgetFail
+
+	// BudgetBlock waits until enough bytes are released, or the context is done.
+	BudgetBlock
+)
+
+// budget counts the outstanding bytes of a pool.
+type budget struct {
+	mutex       sync.Mutex
+	outstanding int64
+
+	// released is closed when some bytes are released.
+	released chan struct{}
+}
+
+// acquire counts n outstanding bytes.
+// If the count exceeds max, it returns false in BudgetFallback mode,
+// ErrBudgetExceeded in BudgetFail mode, or waits in BudgetBlock mode.
+// The bytes larger than max are acquired if no bytes is outstanding, so the waiting is not endless.
+func (b *budget) acquire(ctx context.Context, n int, max int64, mode BudgetMode) (bool, error) {
+	for {
+		b.mutex.Lock()
+		if b.outstanding+int64(n) <= max || b.outstanding == 0 {
+			b.outstanding += int64(n)
+			b.mutex.Unlock()
+			return true, nil
+		}
+
+		switch mode {
+		case BudgetFail:
+			b.mutex.Unlock()
+			return false, ErrBudgetExceeded
+		case BudgetBlock:
+			if b.released == nil {
+				b.released = make(chan struct{})
+			}
+			released := b.released
+			b.mutex.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-released:
+			}
+		default:
+			b.mutex.Unlock()
+			return false, nil
+		}
+	}
+}
+
+// release counts down n outstanding bytes, and wakes the waiters.
+func (b *budget) release(n int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.outstanding -= int64(n)
+	if b.outstanding < 0 {
+		// the released bytes were acquired before MaxOutstandingBytes was set.
+		b.outstanding = 0
+	}
+	if b.released != nil {
+		close(b.released)
+		b.released = nil
+	}
+}
+
+// load returns the count of the outstanding bytes.
+func (b *budget) load() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.outstanding
+}
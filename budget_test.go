@@ -0,0 +1,188 @@
+package bytespool
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetFail(t *testing.T) {
+	pool := BytesPool{
+		MaxOutstandingBytes: 256,
+		BudgetMode:          BudgetFail,
+	}
+
+	bytes1 := pool.Get(100)
+	bytes2 := pool.Get(100)
+	if _, err := pool.GetContext(context.Background(), 1); err != ErrBudgetExceeded {
+		t.Fatalf("error missmatch, want: %v, have: %v", ErrBudgetExceeded, err)
+		return
+	}
+
+	pool.Put(bytes1)
+	if _, err := pool.GetContext(context.Background(), 100); err != nil {
+		t.Fatal(err)
+		return
+	}
+	pool.Put(bytes2)
+}
+
+func TestBudgetFallback(t *testing.T) {
+	pool := BytesPool{MaxOutstandingBytes: 128}
+
+	_ = pool.Get(100)
+	bytes := pool.Get(100)
+	if len(bytes) != 100 || cap(bytes) != 100 {
+		t.Fatalf("fallback bytes error, want: len %d cap %d, have: len %d cap %d", 100, 100, len(bytes), cap(bytes))
+		return
+	}
+	if gets := pool.Stats().Classes[pool.getIndex(100)].Gets; gets != 1 {
+		t.Fatalf("gets error, want: %d, have: %d", 1, gets)
+	}
+}
+
+func TestBudgetFallbackPut(t *testing.T) {
+	for _, debug := range []DebugFlags{0, DebugTrackPuts} {
+		pool := BytesPool{
+			MaxOutstandingBytes: 4096,
+			Debug:               debug,
+			ForeignPolicy:       ForeignPanic,
+		}
+
+		bytes1 := pool.Get(4096)
+		bytes2 := pool.Get(4096)
+		if _, found := pool.findIndex(cap(bytes2)); found {
+			t.Fatalf("capacity of the fallback bytes should not be of any size class, have: %d", cap(bytes2))
+			return
+		}
+		pool.Put(bytes2)
+
+		// the fallback bytes released nothing, bytes1 is still outstanding.
+		pool.BudgetMode = BudgetFail
+		if _, err := pool.GetContext(context.Background(), 4096); err != ErrBudgetExceeded {
+			t.Fatalf("error missmatch, want: %v, have: %v", ErrBudgetExceeded, err)
+			return
+		}
+		pool.Put(bytes1)
+		if _, err := pool.GetContext(context.Background(), 4096); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+}
+
+func TestBudgetBlock(t *testing.T) {
+	pool := BytesPool{
+		MaxOutstandingBytes: 128,
+		BudgetMode:          BudgetBlock,
+	}
+
+	bytes := pool.Get(100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if _, err := pool.GetContext(ctx, 100); err != context.DeadlineExceeded {
+		t.Fatalf("error missmatch, want: %v, have: %v", context.DeadlineExceeded, err)
+		return
+	}
+
+	go func() {
+		time.Sleep(time.Millisecond * 10)
+		pool.Put(bytes)
+	}()
+	if _, err := pool.GetContext(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBudgetOversize(t *testing.T) {
+	pool := BytesPool{
+		MaxOutstandingBytes: 2 * 1024 * 1024,
+		BudgetMode:          BudgetFail,
+		SizeClasses:         []int{64, 128},
+	}
+
+	bytes1 := pool.Get(1024 * 1024)
+	_ = pool.Get(1024 * 1024)
+
+	// the foreign oversize bytes releases nothing.
+	pool.Put(make([]byte, 0, 3*1024*1024))
+	if _, err := pool.GetContext(context.Background(), 64); err != ErrBudgetExceeded {
+		t.Fatalf("error missmatch, want: %v, have: %v", ErrBudgetExceeded, err)
+		return
+	}
+
+	pool.Put(bytes1)
+	if _, err := pool.GetContext(context.Background(), 1024*1024); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBudgetBuffer(t *testing.T) {
+	pool := &BytesPool{
+		MaxOutstandingBytes: 1024,
+		BudgetMode:          BudgetFail,
+	}
+	bytes := pool.Get(1024)
+
+	buffer := Buffer{BytesPool: pool}
+	if _, err := buffer.Write([]byte("hello")); err != ErrBudgetExceeded {
+		t.Fatalf("write error, want: %v, have: %v", ErrBudgetExceeded, err)
+		return
+	}
+	if _, err := buffer.ReadFrom(strings.NewReader("hello")); err != ErrBudgetExceeded {
+		t.Fatalf("read from error, want: %v, have: %v", ErrBudgetExceeded, err)
+		return
+	}
+	if err := buffer.GrowContext(context.Background(), 5); err != ErrBudgetExceeded {
+		t.Fatalf("grow error, want: %v, have: %v", ErrBudgetExceeded, err)
+		return
+	}
+	if buffer.Len() != 0 || buffer.Cap() != 0 {
+		t.Fatalf("the failed buffer should be empty, have: len %d cap %d", buffer.Len(), buffer.Cap())
+		return
+	}
+
+	pool.BudgetMode = BudgetBlock
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if err := buffer.GrowContext(ctx, 5); err != context.DeadlineExceeded {
+		t.Fatalf("grow error, want: %v, have: %v", context.DeadlineExceeded, err)
+		return
+	}
+
+	pool.Put(bytes)
+	if _, err := buffer.WriteString("hello"); err != nil || buffer.String() != "hello" {
+		t.Fatalf("write error, have: %q, %v", buffer.String(), err)
+		return
+	}
+	buffer.Reset()
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("outstanding bytes error, want: %d, have: %d", 0, outstanding)
+	}
+}
+
+func TestMaxIdleBytes(t *testing.T) {
+	pool := BytesPool{
+		MaxIdleBytes:     128,
+		SizedPoolFactory: BoundedPoolFactory(10, 0),
+	}
+
+	bytes1 := pool.Get(100)
+	bytes2 := pool.Get(100)
+	pool.Put(bytes1)
+	pool.Put(bytes2) // beyond the idle budget, dropped
+
+	if idle := pool.Stats().IdleBytes(); idle != 128 {
+		t.Fatalf("idle bytes error, want: %d, have: %d", 128, idle)
+		return
+	}
+
+	_ = pool.Get(100)
+	_ = pool.Get(100)
+	class := pool.Stats().Classes[pool.getIndex(100)]
+	if class.News != 3 || class.Idle != 0 {
+		t.Fatalf("counter error, want: %d news and %d idle, have: %d news and %d idle", 3, 0, class.News, class.Idle)
+	}
+}
@@ -1,8 +1,11 @@
 package bytespool
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
-	"sync"
+	"unicode/utf8"
 )
 
 var (
@@ -15,32 +18,95 @@ var (
 	// DefaultBufferReserveLength is the default value of Buffer..
 	DefaultBufferReserveLength = 1024
 
+	// BufferLeakTracker records the buffers acquired by GetBuffer until they are released by PutBuffer.
+	// It should be set before any buffer is acquired.
+	// default is nil, no record.
+	BufferLeakTracker *LeakTracker
+
 	// BufferPool is the pool of Buffer instance.
-	BufferPool Pool = &sync.Pool{
-		New: func() interface{} {
+	// It resets the released buffers.
+	BufferPool = &TypedPool[*Buffer]{
+		New: func() *Buffer {
 			return new(Buffer)
 		},
+		Reset: (*Buffer).Reset,
 	}
 )
 
+var (
+	// ErrUnreadByte is returned by Buffer.UnreadByte if the previous operation was not a successful read.
+	ErrUnreadByte = errors.New("bytespool.Buffer: UnreadByte: previous operation was not a successful read")
+
+	// ErrUnreadRune is returned by Buffer.UnreadRune if the previous operation was not a successful ReadRune.
+	ErrUnreadRune = errors.New("bytespool.Buffer: UnreadRune: previous operation was not a successful ReadRune")
+)
+
+// readOp is the last read operation of a Buffer, so UnreadRune and UnreadByte can check for invalid usage.
+// The positive values are the sizes of the rune read by ReadRune.
+type readOp int8
+
+const (
+	opRead      readOp = -1 // any other read operation
+	opInvalid   readOp = 0  // non-read operation
+	opReadRune1 readOp = 1  // read rune of size 1
+)
+
 // GetBuffer acquire a buffer at default bytes pool.
 func GetBuffer() *Buffer {
-	return BufferPool.Get().(*Buffer)
+	buffer := BufferPool.Get()
+	if BufferLeakTracker != nil {
+		BufferLeakTracker.track(bufferPointer(buffer), "buffer", 0)
+	}
+	return buffer
 }
 
 // PutBuffer reset and release buffer.
 func PutBuffer(buffer *Buffer) {
-	buffer.Reset()
+	if BufferLeakTracker != nil {
+		BufferLeakTracker.untrack(bufferPointer(buffer))
+	}
 	BufferPool.Put(buffer)
 }
 
+// PrewarmBuffers creates count buffers that can hold length bytes and releases them in advance.
+// The bytes larger than the reserve length of the buffers are released to the default bytes pool.
+func PrewarmBuffers(count, length int) {
+	buffers := make([]*Buffer, count)
+	for idx := range buffers {
+		buffer := BufferPool.New()
+		buffer.Grow(length)
+		buffers[idx] = buffer
+	}
+	for _, buffer := range buffers {
+		BufferPool.release(buffer)
+	}
+}
+
 // SizedBytesPool is a interface that represents a pool of sized bytes.
 type SizedBytesPool interface {
 	Get(length int) []byte
 	Put([]byte)
 }
 
+// AlignedBytesPool is a interface that represents a pool of the aligned bytes.
+type AlignedBytesPool interface {
+	SizedBytesPool
+	GetAligned(length, alignment int) []byte
+}
+
+// ContextBytesPool is a interface that represents a pool which may fail or wait to acquire bytes,
+// like a BytesPool with MaxOutstandingBytes.
+type ContextBytesPool interface {
+	SizedBytesPool
+	GetContext(ctx context.Context, length int) ([]byte, error)
+	GetAlignedContext(ctx context.Context, length, alignment int) ([]byte, error)
+}
+
 // Buffer get bytes from pool and put idle bytes to pool.
+// If BytesPool is a ContextBytesPool, like a BytesPool with MaxOutstandingBytes,
+// the writes return the error of the pool, like ErrBudgetExceeded, instead of panicking.
+// GrowContext and ReadFromContext stop waiting for the bytes when the context is done,
+// the other methods wait without a deadline in BudgetBlock mode.
 type Buffer struct {
 	// BytesPool is a pool of bytes of buffer.
 	// default is DefaultBytesPool.
@@ -59,14 +125,36 @@ type Buffer struct {
 	// default is DefaultBufferReserveLength.
 	ReserveLength int
 
+	// Alignment is the alignment of the start of the bytes, a power of two.
+	// BytesPool must be a AlignedBytesPool if it is set.
+	// default is 0, no alignment.
+	Alignment int
+
 	bytes []byte
 
+	// bytesPool is the pool which the bytes come from.
+	bytesPool SizedBytesPool
+
 	readOffset int
+	lastRead   readOp
 }
 
-// Bytes returns bytes of buffer.
+// Bytes returns the unread portion of the buffer.
+// The slice is valid only until the next modification of the buffer.
 func (buffer *Buffer) Bytes() []byte {
-	return buffer.bytes
+	if buffer.bytes == nil {
+		return nil
+	}
+	return buffer.bytes[buffer.readOffset:]
+}
+
+// String returns the unread portion of the buffer as a string.
+// If the buffer is a nil pointer, it returns "<nil>".
+func (buffer *Buffer) String() string {
+	if buffer == nil {
+		return "<nil>"
+	}
+	return string(buffer.Bytes())
 }
 
 // Cap returns the capacity of the bytes of buffer.
@@ -77,48 +165,232 @@ func (buffer *Buffer) Cap() int {
 	return cap(buffer.bytes)
 }
 
-// Len returns the size of the bytes of buffer.
+// Len returns the number of bytes of the unread portion of the buffer.
 func (buffer *Buffer) Len() int {
 	if buffer.bytes == nil {
 		return 0
 	}
-	return len(buffer.bytes)
+	return len(buffer.bytes) - buffer.readOffset
 }
 
-// unreadLength returns the number of bytes of the unread portion of the buffer.
-func (buffer *Buffer) unreadLength() int {
+// Available returns how many bytes can be written without growing the buffer.
+func (buffer *Buffer) Available() int {
+	return buffer.writeableLen()
+}
+
+// AvailableBuffer returns a empty slice with Available capacity,
+// which is intended to be appended to and passed to an immediately succeeding Write.
+// The slice is valid only until the next modification of the buffer.
+func (buffer *Buffer) AvailableBuffer() []byte {
 	if buffer.bytes == nil {
-		return 0
+		return nil
 	}
-	return len(buffer.bytes) - buffer.readOffset
+	length := len(buffer.bytes)
+	return buffer.bytes[length : length : length+buffer.writeableLen()]
+}
+
+// empty drops the data and keeps the bytes.
+func (buffer *Buffer) empty() {
+	if buffer.bytes != nil {
+		buffer.bytes = buffer.bytes[:0]
+	}
+	buffer.readOffset = 0
+	buffer.lastRead = opInvalid
+}
+
+// Truncate discards all but the first n unread bytes from the buffer, and keeps the bytes.
+// It panics if n is negative or greater than the length of the buffer.
+func (buffer *Buffer) Truncate(n int) {
+	if n == 0 {
+		buffer.empty()
+		return
+	}
+	buffer.lastRead = opInvalid
+	if n < 0 || n > buffer.Len() {
+		panic("bytespool.Buffer: truncation out of range")
+	}
+	buffer.bytes = buffer.bytes[:buffer.readOffset+n]
 }
 
 // Read reads the next len(p) bytes from the buffer or until the buffer is drained.
 // The return first value is the number of bytes read.
-// If the buffer has no data to return, return 0, io.EOF.
+// If the buffer has no data to return, return 0, io.EOF, unless len(p) is zero.
 func (buffer *Buffer) Read(p []byte) (int, error) {
-	if buffer.unreadLength() == 0 {
+	buffer.lastRead = opInvalid
+	if buffer.Len() == 0 {
+		buffer.empty()
+		if len(p) == 0 {
+			return 0, nil
+		}
 		return 0, io.EOF
 	}
 
 	nRead := copy(p, buffer.bytes[buffer.readOffset:])
 	buffer.readOffset += nRead
+	if nRead > 0 {
+		buffer.lastRead = opRead
+	}
 	return nRead, nil
 }
 
+// Next returns a slice containing the next n bytes from the buffer,
+// advancing the buffer as if the bytes had been returned by Read.
+// If there are fewer than n bytes in the buffer, Next returns the entire buffer.
+// The slice is valid only until the next modification of the buffer.
+func (buffer *Buffer) Next(n int) []byte {
+	buffer.lastRead = opInvalid
+	if length := buffer.Len(); n > length {
+		n = length
+	}
+	if buffer.bytes == nil {
+		return nil
+	}
+	data := buffer.bytes[buffer.readOffset : buffer.readOffset+n]
+	buffer.readOffset += n
+	if n > 0 {
+		buffer.lastRead = opRead
+	}
+	return data
+}
+
+// ReadByte reads and returns the next byte from the buffer.
+// If no byte is available, it returns error io.EOF.
+func (buffer *Buffer) ReadByte() (byte, error) {
+	if buffer.Len() == 0 {
+		buffer.empty()
+		return 0, io.EOF
+	}
+
+	c := buffer.bytes[buffer.readOffset]
+	buffer.readOffset++
+	buffer.lastRead = opRead
+	return c, nil
+}
+
+// ReadRune reads and returns the next UTF-8-encoded Unicode code point from the buffer.
+// If no bytes are available, the error returned is io.EOF.
+// If the bytes are an erroneous UTF-8 encoding, it consumes one byte and returns U+FFFD, 1.
+func (buffer *Buffer) ReadRune() (rune, int, error) {
+	if buffer.Len() == 0 {
+		buffer.empty()
+		return 0, 0, io.EOF
+	}
+
+	c := buffer.bytes[buffer.readOffset]
+	if c < utf8.RuneSelf {
+		buffer.readOffset++
+		buffer.lastRead = opReadRune1
+		return rune(c), 1, nil
+	}
+	r, size := utf8.DecodeRune(buffer.bytes[buffer.readOffset:])
+	buffer.readOffset += size
+	buffer.lastRead = readOp(size)
+	return r, size, nil
+}
+
+// UnreadRune unreads the last rune returned by ReadRune.
+// If the most recent read or write operation on the buffer was not a successful ReadRune,
+// it returns ErrUnreadRune.
+func (buffer *Buffer) UnreadRune() error {
+	if buffer.lastRead <= opInvalid {
+		return ErrUnreadRune
+	}
+	if buffer.readOffset >= int(buffer.lastRead) {
+		buffer.readOffset -= int(buffer.lastRead)
+	}
+	buffer.lastRead = opInvalid
+	return nil
+}
+
+// UnreadByte unreads the last byte returned by the most recent successful read operation
+// that read at least one byte. If a write has happened since the last read,
+// if the last read returned an error, or if the read read zero bytes, it returns ErrUnreadByte.
+func (buffer *Buffer) UnreadByte() error {
+	if buffer.lastRead == opInvalid {
+		return ErrUnreadByte
+	}
+	buffer.lastRead = opInvalid
+	if buffer.readOffset > 0 {
+		buffer.readOffset--
+	}
+	return nil
+}
+
+// ReadBytes reads until the first occurrence of delim in the input,
+// returning a slice containing the data up to and including the delimiter.
+// If it encounters the end of the buffer before finding a delimiter,
+// it returns the data read before the end and io.EOF.
+func (buffer *Buffer) ReadBytes(delim byte) ([]byte, error) {
+	slice, err := buffer.readSlice(delim)
+	// the slice is valid only until the next modification of the buffer.
+	return append([]byte(nil), slice...), err
+}
+
+// ReadString reads until the first occurrence of delim in the input,
+// returning a string containing the data up to and including the delimiter.
+// If it encounters the end of the buffer before finding a delimiter,
+// it returns the data read before the end and io.EOF.
+func (buffer *Buffer) ReadString(delim byte) (string, error) {
+	slice, err := buffer.readSlice(delim)
+	return string(slice), err
+}
+
+// readSlice is like ReadBytes but returns a reference to the bytes of the buffer.
+func (buffer *Buffer) readSlice(delim byte) ([]byte, error) {
+	unread := buffer.Bytes()
+	end := bytes.IndexByte(unread, delim) + 1
+	var err error
+	if end <= 0 {
+		end = len(unread)
+		err = io.EOF
+	}
+	buffer.readOffset += end
+	buffer.lastRead = opRead
+	return unread[:end], err
+}
+
+func (buffer *Buffer) writeable() bool {
+	// the reserved bytes come from other pool, they are not writeable.
+	if buffer.bytesPool != buffer.pool() {
+		return false
+	}
+	// nor the misaligned bytes.
+	if buffer.Alignment > 0 && arrayPointer(buffer.bytes)%uintptr(buffer.Alignment) != 0 {
+		return false
+	}
+	return true
+}
+
 func (buffer *Buffer) writeableLen() int {
-	return buffer.Cap() - buffer.Len()
+	if !buffer.writeable() {
+		return 0
+	}
+	return cap(buffer.bytes) - len(buffer.bytes)
+}
+
+func (buffer *Buffer) pool() SizedBytesPool {
+	if buffer.BytesPool == nil {
+		return DefaultBytesPool
+	}
+	return buffer.BytesPool
 }
 
 // ReadFrom reads data from r until EOF and appends it to the buffer, growing the buffer as needed.
 func (buffer *Buffer) ReadFrom(r io.Reader) (int64, error) {
+	return buffer.ReadFromContext(context.Background(), r)
+}
+
+// ReadFromContext is like ReadFrom, but stops waiting for the bytes to grow the buffer
+// and returns the error of the context when the context is done.
+func (buffer *Buffer) ReadFromContext(ctx context.Context, r io.Reader) (int64, error) {
+	buffer.lastRead = opInvalid
 	var nRead int64
 	for {
 		if buffer.ReadBuffLength == 0 {
 			buffer.ReadBuffLength = DefaultBufferReadBuffLength
 		}
-		if buffer.writeableLen() < buffer.ReadBuffLength {
-			buffer.grow(buffer.ReadBuffLength)
+		if err := buffer.ensure(ctx, buffer.ReadBuffLength); err != nil {
+			return nRead, err
 		}
 
 		buff := buffer.bytes[len(buffer.bytes):cap(buffer.bytes)]
@@ -136,12 +408,13 @@ func (buffer *Buffer) ReadFrom(r io.Reader) (int64, error) {
 
 // Write appends the contents of p to the buffer, growing the buffer as needed.
 func (buffer *Buffer) Write(p []byte) (int, error) {
+	buffer.lastRead = opInvalid
 	if p == nil || len(p) == 0 {
 		return 0, nil
 	}
 
-	if buffer.writeableLen() < len(p) {
-		buffer.grow(len(p))
+	if err := buffer.ensure(context.Background(), len(p)); err != nil {
+		return 0, err
 	}
 
 	buff := buffer.bytes[len(buffer.bytes):cap(buffer.bytes)]
@@ -151,26 +424,94 @@ func (buffer *Buffer) Write(p []byte) (int, error) {
 	return nWrote, nil
 }
 
+// WriteByte appends the byte c to the buffer, growing the buffer as needed.
+// The returned error is nil, unless the buffer fails to grow.
+func (buffer *Buffer) WriteByte(c byte) error {
+	buffer.lastRead = opInvalid
+	if err := buffer.ensure(context.Background(), 1); err != nil {
+		return err
+	}
+
+	buffer.bytes = append(buffer.bytes, c)
+	return nil
+}
+
+// WriteRune appends the UTF-8 encoding of Unicode code point r to the buffer, growing the buffer as needed.
+// It returns the length of the encoding, and the error is nil, unless the buffer fails to grow.
+func (buffer *Buffer) WriteRune(r rune) (int, error) {
+	if uint32(r) < utf8.RuneSelf {
+		if err := buffer.WriteByte(byte(r)); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	buffer.lastRead = opInvalid
+	if err := buffer.ensure(context.Background(), utf8.UTFMax); err != nil {
+		return 0, err
+	}
+
+	length := len(buffer.bytes)
+	buffer.bytes = utf8.AppendRune(buffer.bytes, r)
+	return len(buffer.bytes) - length, nil
+}
+
 // WriteTo writes data to w until the buffer is drained or an error occurs.
+// It retries the partial writes, and returns io.ErrShortWrite if a write makes no progress without an error.
 func (buffer *Buffer) WriteTo(w io.Writer) (int64, error) {
-	if buffer.unreadLength() == 0 {
-		return 0, nil
+	return buffer.WriteToN(w, buffer.Len())
+}
+
+// WriteToN writes the next n bytes, or all if the buffer has fewer, to w,
+// until they are written or an error occurs.
+// It retries the partial writes, and returns io.ErrShortWrite if a write makes no progress without an error.
+// It panics if n is negative.
+func (buffer *Buffer) WriteToN(w io.Writer, n int) (int64, error) {
+	if n < 0 {
+		panic("bytespool.Buffer.WriteToN: negative count")
+	}
+	buffer.lastRead = opInvalid
+	if length := buffer.Len(); n > length {
+		n = length
+	}
+
+	var nWrote int64
+	var err error
+	for n > 0 {
+		buff := buffer.bytes[buffer.readOffset : buffer.readOffset+n]
+		var m int
+		m, err = w.Write(buff)
+		if m < 0 || m > len(buff) {
+			panic("bytespool.Buffer.WriteToN: invalid Write count")
+		}
+		buffer.readOffset += m
+		nWrote += int64(m)
+		n -= m
+		if err != nil {
+			break
+		}
+		if m == 0 {
+			err = io.ErrShortWrite
+			break
+		}
 	}
-	buff := buffer.bytes[buffer.readOffset:buffer.Len()]
-	nWrote, err := w.Write(buff)
-	buffer.readOffset += nWrote
-	return int64(nWrote), err
+
+	if buffer.Len() == 0 {
+		buffer.empty()
+	}
+	return nWrote, err
 }
 
 // WriteString appends the contents of str to the buffer, growing the buffer as needed.
 // The return first value is the length of str.
 func (buffer *Buffer) WriteString(str string) (int, error) {
+	buffer.lastRead = opInvalid
 	if len(str) == 0 {
 		return 0, nil
 	}
 
-	if buffer.writeableLen() < len(str) {
-		buffer.grow(len(str))
+	if err := buffer.ensure(context.Background(), len(str)); err != nil {
+		return 0, err
 	}
 
 	buff := buffer.bytes[len(buffer.bytes):cap(buffer.bytes)]
@@ -180,39 +521,114 @@ func (buffer *Buffer) WriteString(str string) (int, error) {
 	return len(str), nil
 }
 
-func (buffer *Buffer) grow(n int) {
+// ensure grows the buffer if less than n bytes can be written.
+func (buffer *Buffer) ensure(ctx context.Context, n int) error {
+	if buffer.writeableLen() >= n {
+		return nil
+	}
+	return buffer.grow(ctx, n)
+}
+
+// acquire acquires the bytes of length from the pool, with the alignment of the buffer.
+func (buffer *Buffer) acquire(ctx context.Context, length int) ([]byte, error) {
+	if contextPool, ok := buffer.BytesPool.(ContextBytesPool); ok {
+		if buffer.Alignment > 0 {
+			return contextPool.GetAlignedContext(ctx, length, buffer.Alignment)
+		}
+		return contextPool.GetContext(ctx, length)
+	}
+
+	if buffer.Alignment > 0 {
+		alignedPool, ok := buffer.BytesPool.(AlignedBytesPool)
+		if !ok {
+			panic("bytes pool of the aligned buffer must be a AlignedBytesPool")
+		}
+		return alignedPool.GetAligned(length, buffer.Alignment), nil
+	}
+	return buffer.BytesPool.Get(length), nil
+}
+
+func (buffer *Buffer) grow(ctx context.Context, n int) error {
 	if buffer.BytesPool == nil {
 		buffer.BytesPool = DefaultBytesPool
 	}
 
-	length := buffer.Len() - buffer.readOffset + n
+	// keep the last read bytes, so they can be unread after the growth.
+	var keep int
+	switch {
+	case buffer.lastRead == opRead:
+		keep = 1
+	case buffer.lastRead > opInvalid:
+		keep = int(buffer.lastRead)
+	}
+	if keep > buffer.readOffset {
+		keep = buffer.readOffset
+	}
+	start := buffer.readOffset - keep
+
+	length := keep + buffer.Len() + n
+	if start > 0 && buffer.writeable() && length <= cap(buffer.bytes)/2 {
+		// slide the unread portion down instead of getting new bytes, like bytes.Buffer.
+		nCopy := copy(buffer.bytes, buffer.bytes[start:])
+		buffer.bytes = buffer.bytes[:nCopy]
+		buffer.readOffset = keep
+		return nil
+	}
+
 	if buffer.MinGrowLength == 0 {
 		buffer.MinGrowLength = DefaultBufferMinGrowLength
 	}
 	if length < buffer.MinGrowLength {
 		length = buffer.MinGrowLength
 	}
-	bytes := buffer.BytesPool.Get(length)
+	bytes, err := buffer.acquire(ctx, length)
+	if err != nil {
+		return err
+	}
 
 	if buffer.bytes != nil {
-		nCopy := copy(bytes, buffer.bytes[buffer.readOffset:])
+		nCopy := copy(bytes, buffer.bytes[start:])
 		bytes = bytes[:nCopy]
-		buffer.BytesPool.Put(buffer.bytes)
+		buffer.bytesPool.Put(buffer.bytes)
 	} else {
 		bytes = bytes[:0]
 	}
 	buffer.bytes = bytes
-	buffer.readOffset = 0
+	buffer.bytesPool = buffer.BytesPool
+	buffer.readOffset = keep
+	return nil
 }
 
 // Grow grows the buffer's capacity.
 // After Grow(n), at least n bytes can be written to the buffer without another allocation.
+// It panics if n is negative, or with the error of the pool if the buffer fails to grow,
+// see GrowContext.
 func (buffer *Buffer) Grow(n int) {
-	if buffer.writeableLen() >= n {
-		return
+	if err := buffer.GrowContext(context.Background(), n); err != nil {
+		panic(err)
 	}
+}
+
+// GrowContext is like Grow, but returns the error of the pool if the buffer fails to grow,
+// or the error of the context if the context is done before the bytes are acquired.
+func (buffer *Buffer) GrowContext(ctx context.Context, n int) error {
+	if n < 0 {
+		panic("bytespool.Buffer.Grow: negative count")
+	}
+	if buffer.Len() == 0 && buffer.readOffset != 0 {
+		buffer.empty()
+	}
+	return buffer.ensure(ctx, n)
+}
 
-	buffer.grow(n)
+// ResetSecure zeroes the bytes up to the capacity, then resets the buffer like Reset.
+// The bytes released while the buffer grew are not zeroed by it,
+// use a BytesPool with ZeroOnPut for them.
+func (buffer *Buffer) ResetSecure() {
+	if buffer.bytes != nil {
+		zero(buffer.bytes[:cap(buffer.bytes)])
+	}
+	buffer.Reset()
 }
 
 // Reset release bytes and reset the buffer status.
@@ -221,9 +637,12 @@ func (buffer *Buffer) Reset() {
 		if buffer.ReserveLength == 0 {
 			buffer.ReserveLength = DefaultBufferReserveLength
 		}
-		if cap(buffer.bytes) > buffer.ReserveLength {
-			buffer.BytesPool.Put(buffer.bytes)
+		// only the bytes of the default pool are reserved,
+		// a reused buffer should not hold the bytes of other pool.
+		if cap(buffer.bytes) > buffer.ReserveLength || buffer.bytesPool != DefaultBytesPool {
+			buffer.bytesPool.Put(buffer.bytes)
 			buffer.bytes = nil
+			buffer.bytesPool = nil
 		} else {
 			buffer.bytes = buffer.bytes[:0]
 		}
@@ -231,8 +650,10 @@ func (buffer *Buffer) Reset() {
 
 	buffer.BytesPool = nil
 	buffer.readOffset = 0
+	buffer.lastRead = opInvalid
 
 	buffer.MinGrowLength = 0
 	buffer.ReadBuffLength = 0
 	buffer.ReserveLength = 0
+	buffer.Alignment = 0
 }
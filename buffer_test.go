@@ -5,9 +5,13 @@ import (
 	"crypto/md5"
 	"io"
 	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestBufferBasic(t *testing.T) {
@@ -207,8 +211,12 @@ func TestBufferManyRead(t *testing.T) {
 		}
 	}
 
-	if length != buffer.Len() {
-		t.Fatalf("length missmatch, want: %d, have: %d", buffer.Len(), length)
+	if length != nRead {
+		t.Fatalf("length missmatch, want: %d, have: %d", nRead, length)
+		return
+	}
+	if buffer.Len() != 0 {
+		t.Fatalf("unread length error, want: %d, have: %d", 0, buffer.Len())
 		return
 	}
 
@@ -367,3 +375,291 @@ func TestBufferReuseBytes(t *testing.T) {
 		return
 	}
 }
+
+func TestBufferAlignment(t *testing.T) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+	buffer.Alignment = 4096
+
+	for i := 0; i < 10; i++ {
+		_, err := buffer.Write(make([]byte, 1000))
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if arrayPointer(buffer.Bytes())%4096 != 0 {
+			t.Fatalf("bytes not aligned to %d, length: %d", 4096, buffer.Len())
+			return
+		}
+	}
+}
+
+func TestBufferResetSecure(t *testing.T) {
+	buffer := GetBuffer()
+	buffer.WriteString("secret")
+	bytes := buffer.Bytes()[:cap(buffer.Bytes())]
+
+	buffer.ResetSecure()
+	for idx, b := range bytes {
+		if b != 0 {
+			t.Fatalf("bytes should be zeroed, have: %d at %d", b, idx)
+			return
+		}
+	}
+	PutBuffer(buffer)
+}
+
+// checkBuffer compares the buffer with the bytes.Buffer.
+func checkBuffer(t *testing.T, step int, buffer *Buffer, want *bytes.Buffer) bool {
+	t.Helper()
+	if buffer.Len() != want.Len() {
+		t.Fatalf("step %d: length error, want: %d, have: %d", step, want.Len(), buffer.Len())
+		return false
+	}
+	if !bytes.Equal(buffer.Bytes(), want.Bytes()) {
+		t.Fatalf("step %d: bytes error, want: %q, have: %q", step, want.Bytes(), buffer.Bytes())
+		return false
+	}
+	if buffer.String() != want.String() {
+		t.Fatalf("step %d: string error, want: %q, have: %q", step, want.String(), buffer.String())
+		return false
+	}
+	return true
+}
+
+func TestBufferParity(t *testing.T) {
+	rand.Seed(time.Now().Unix())
+	runes := []rune{'a', 'z', '\n', 'é', '世', '😀', utf8.RuneError, -1}
+
+	for round := 0; round < 100; round++ {
+		buffer := GetBuffer()
+		var want bytes.Buffer
+
+		for step := 0; step < 200; step++ {
+			var have, expect interface{}
+			switch op := rand.Intn(15); op {
+			case 0:
+				p := make([]byte, rand.Intn(300))
+				rand.Read(p)
+				n1, err1 := buffer.Write(p)
+				n2, err2 := want.Write(p)
+				have, expect = []interface{}{n1, err1}, []interface{}{n2, err2}
+			case 1:
+				str := strings.Repeat("x\n", rand.Intn(50))
+				n1, err1 := buffer.WriteString(str)
+				n2, err2 := want.WriteString(str)
+				have, expect = []interface{}{n1, err1}, []interface{}{n2, err2}
+			case 2:
+				c := byte(rand.Intn(256))
+				have, expect = buffer.WriteByte(c), want.WriteByte(c)
+			case 3:
+				r := runes[rand.Intn(len(runes))]
+				n1, err1 := buffer.WriteRune(r)
+				n2, err2 := want.WriteRune(r)
+				have, expect = []interface{}{n1, err1}, []interface{}{n2, err2}
+			case 4:
+				p1, p2 := make([]byte, rand.Intn(100)), make([]byte, 0)
+				p2 = append(p2, p1...)
+				n1, err1 := buffer.Read(p1)
+				n2, err2 := want.Read(p2)
+				have, expect = []interface{}{n1, err1, p1[:n1]}, []interface{}{n2, err2, p2[:n2]}
+			case 5:
+				c1, err1 := buffer.ReadByte()
+				c2, err2 := want.ReadByte()
+				have, expect = []interface{}{c1, err1}, []interface{}{c2, err2}
+			case 6:
+				have, expect = buffer.UnreadByte() == nil, want.UnreadByte() == nil
+			case 7:
+				r1, n1, err1 := buffer.ReadRune()
+				r2, n2, err2 := want.ReadRune()
+				have, expect = []interface{}{r1, n1, err1}, []interface{}{r2, n2, err2}
+			case 8:
+				have, expect = buffer.UnreadRune() == nil, want.UnreadRune() == nil
+			case 9:
+				line1, err1 := buffer.ReadBytes('\n')
+				line2, err2 := want.ReadBytes('\n')
+				have, expect = []interface{}{string(line1), err1}, []interface{}{string(line2), err2}
+			case 10:
+				line1, err1 := buffer.ReadString('\n')
+				line2, err2 := want.ReadString('\n')
+				have, expect = []interface{}{line1, err1}, []interface{}{line2, err2}
+			case 11:
+				n := rand.Intn(100)
+				have, expect = string(buffer.Next(n)), string(want.Next(n))
+			case 12:
+				n := 0
+				if want.Len() > 0 {
+					n = rand.Intn(want.Len() + 1)
+				}
+				buffer.Truncate(n)
+				want.Truncate(n)
+			case 13:
+				n := rand.Intn(1000)
+				buffer.Grow(n)
+				want.Grow(n)
+				// bytes.Buffer may lose the last read byte when Grow slides the data,
+				// while Buffer keeps it, so no unread after Grow.
+				buffer.Write(nil)
+				want.Write(nil)
+				if buffer.Available() < n {
+					t.Fatalf("step %d: available error, want: >= %d, have: %d", step, n, buffer.Available())
+					return
+				}
+			case 14:
+				var w1, w2 bytes.Buffer
+				n1, err1 := buffer.WriteTo(&w1)
+				n2, err2 := want.WriteTo(&w2)
+				have, expect = []interface{}{n1, err1, w1.String()}, []interface{}{n2, err2, w2.String()}
+			}
+
+			if !reflect.DeepEqual(have, expect) {
+				t.Fatalf("step %d: result error, want: %v, have: %v", step, expect, have)
+				return
+			}
+			if !checkBuffer(t, step, buffer, &want) {
+				return
+			}
+		}
+
+		PutBuffer(buffer)
+	}
+}
+
+func TestBufferAvailableBuffer(t *testing.T) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	buffer.WriteString("n=")
+	buffer.Write(strconv.AppendInt(buffer.AvailableBuffer(), 100, 10))
+	if buffer.String() != "n=100" {
+		t.Fatalf("string error, want: %s, have: %s", "n=100", buffer.String())
+		return
+	}
+	if cap(buffer.AvailableBuffer()) != buffer.Available() {
+		t.Fatalf("available error, want: %d, have: %d", buffer.Available(), cap(buffer.AvailableBuffer()))
+	}
+}
+
+func TestBufferUnreadErrors(t *testing.T) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := buffer.UnreadByte(); err != ErrUnreadByte {
+		t.Fatalf("unread byte error, want: %v, have: %v", ErrUnreadByte, err)
+		return
+	}
+	buffer.WriteString("ab")
+	buffer.ReadByte()
+	if err := buffer.UnreadRune(); err != ErrUnreadRune {
+		t.Fatalf("unread rune error, want: %v, have: %v", ErrUnreadRune, err)
+		return
+	}
+
+	var nilBuffer *Buffer
+	if nilBuffer.String() != "<nil>" {
+		t.Fatalf("string error, want: %s, have: %s", "<nil>", nilBuffer.String())
+	}
+}
+
+func TestBufferUnreadAfterGrow(t *testing.T) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	buffer.WriteString("世界")
+	buffer.ReadRune()
+	buffer.Grow(buffer.Cap() * 2)
+	if err := buffer.UnreadRune(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if buffer.String() != "世界" {
+		t.Fatalf("string error, want: %s, have: %s", "世界", buffer.String())
+	}
+}
+
+// throttledWriter writes limit bytes at most per call, and fails after fail calls.
+type throttledWriter struct {
+	bytes.Buffer
+	limit int
+	fail  int
+	err   error
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	if w.fail == 0 {
+		return 0, w.err
+	}
+	w.fail--
+	if len(p) > w.limit {
+		p = p[:w.limit]
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestBufferWriteToPartial(t *testing.T) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+	buffer.WriteString("0123456789")
+
+	// partial writes
+	writer := &throttledWriter{limit: 3, fail: -1}
+	n, err := buffer.WriteTo(writer)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if n != 10 || writer.String() != "0123456789" || buffer.Len() != 0 {
+		t.Fatalf("write error, have: %d, %q, unread: %d", n, writer.String(), buffer.Len())
+		return
+	}
+
+	// no progress
+	buffer.WriteString("0123456789")
+	writer = &throttledWriter{limit: 4, fail: 1}
+	n, err = buffer.WriteTo(writer)
+	if err != io.ErrShortWrite {
+		t.Fatalf("error error, want: %v, have: %v", io.ErrShortWrite, err)
+		return
+	}
+	if n != 4 || buffer.String() != "456789" {
+		t.Fatalf("write error, have: %d, unread: %q", n, buffer.String())
+		return
+	}
+
+	// write error
+	writer = &throttledWriter{limit: 4, fail: 1, err: io.ErrClosedPipe}
+	n, err = buffer.WriteTo(writer)
+	if err != io.ErrClosedPipe {
+		t.Fatalf("error error, want: %v, have: %v", io.ErrClosedPipe, err)
+		return
+	}
+	if n != 4 || buffer.String() != "89" {
+		t.Fatalf("write error, have: %d, unread: %q", n, buffer.String())
+	}
+}
+
+func TestBufferWriteToN(t *testing.T) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+	buffer.WriteString("0123456789")
+
+	writer := &throttledWriter{limit: 3, fail: -1}
+	n, err := buffer.WriteToN(writer, 7)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if n != 7 || writer.String() != "0123456" || buffer.String() != "789" {
+		t.Fatalf("write error, have: %d, %q, unread: %q", n, writer.String(), buffer.String())
+		return
+	}
+
+	n, err = buffer.WriteToN(writer, 100)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if n != 3 || buffer.Len() != 0 {
+		t.Fatalf("write error, have: %d, unread: %d", n, buffer.Len())
+	}
+}
@@ -0,0 +1,629 @@
+package bytespool
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// DefaultBufioSize is the default buffer size of Reader and Writer.
+var DefaultBufioSize = 4096
+
+// maxConsecutiveEmptyReads is the number of the empty reads before io.ErrNoProgress, like bufio.
+const maxConsecutiveEmptyReads = 100
+
+var (
+	readerPool = &TypedPool[*Reader]{
+		New: func() *Reader {
+			return new(Reader)
+		},
+		Reset: func(reader *Reader) {
+			reader.Reset(nil)
+			reader.BytesPool = nil
+		},
+	}
+
+	writerPool = &TypedPool[*Writer]{
+		New: func() *Writer {
+			return new(Writer)
+		},
+		Reset: func(writer *Writer) {
+			writer.Reset(nil)
+			writer.BytesPool = nil
+		},
+	}
+)
+
+// GetReader acquires a Reader reading from r, with a buffer of size.
+// If size is not greater than 0, DefaultBufioSize is used.
+func GetReader(r io.Reader, size int) *Reader {
+	if size <= 0 {
+		size = DefaultBufioSize
+	}
+	reader := readerPool.Get()
+	reader.rd = r
+	reader.size = size
+	return reader
+}
+
+// PutReader releases the buffer of the reader, and releases the reader.
+// The buffered bytes are discarded.
+func PutReader(reader *Reader) {
+	readerPool.Put(reader)
+}
+
+// Reader is a buffered reader like bufio.Reader, with the buffer of a bytes pool.
+// The buffer is acquired when the reader fills it, and released when it is drained,
+// so a idle reader, like the reader of a idle connection, holds no buffer.
+// The reads not smaller than the buffer size bypass the buffer.
+// Unlike bufio.Reader, it has no UnreadByte, UnreadRune or ReadSlice,
+// which need the drained buffer.
+// A Reader is acquired by GetReader, or a zero Reader reads with a buffer of DefaultBufioSize after Reset.
+type Reader struct {
+	// BytesPool is a pool of the buffer.
+	// It must not be changed while the reader holds a buffer.
+	// default is DefaultBytesPool.
+	BytesPool SizedBytesPool
+
+	rd   io.Reader
+	size int
+
+	// buf is nil while nothing is buffered.
+	buf []byte
+	r   int
+	w   int
+	err error
+}
+
+func (reader *Reader) pool() SizedBytesPool {
+	if reader.BytesPool == nil {
+		return DefaultBytesPool
+	}
+	return reader.BytesPool
+}
+
+// Size returns the size of the buffer.
+func (reader *Reader) Size() int {
+	return reader.size
+}
+
+// Buffered returns the number of the bytes that can be read from the buffer.
+func (reader *Reader) Buffered() int {
+	return reader.w - reader.r
+}
+
+// Reset releases the buffer, discards the buffered bytes, and switches the reader to read from r.
+func (reader *Reader) Reset(r io.Reader) {
+	if reader.buf != nil {
+		reader.pool().Put(reader.buf)
+		reader.buf = nil
+	}
+	if reader.size <= 0 {
+		reader.size = DefaultBufioSize
+	}
+	reader.rd = r
+	reader.r = 0
+	reader.w = 0
+	reader.err = nil
+}
+
+// release releases the buffer if it is drained.
+func (reader *Reader) release() {
+	if reader.r == reader.w && reader.buf != nil {
+		reader.pool().Put(reader.buf)
+		reader.buf = nil
+		reader.r = 0
+		reader.w = 0
+	}
+}
+
+// fill reads a new chunk into the buffer, acquiring the buffer if needed.
+func (reader *Reader) fill() {
+	if reader.buf == nil {
+		if reader.size <= 0 {
+			reader.size = DefaultBufioSize
+		}
+		reader.buf = reader.pool().Get(reader.size)[:reader.size]
+	}
+
+	// slide the buffered bytes to the beginning.
+	if reader.r > 0 {
+		copy(reader.buf, reader.buf[reader.r:reader.w])
+		reader.w -= reader.r
+		reader.r = 0
+	}
+
+	if reader.w >= len(reader.buf) {
+		panic("bytespool: tried to fill full buffer")
+	}
+
+	for i := maxConsecutiveEmptyReads; i > 0; i-- {
+		n, err := reader.rd.Read(reader.buf[reader.w:])
+		if n < 0 {
+			panic("bytespool: reader returned negative count from Read")
+		}
+		reader.w += n
+		if err != nil {
+			reader.err = err
+			return
+		}
+		if n > 0 {
+			return
+		}
+	}
+	reader.err = io.ErrNoProgress
+}
+
+func (reader *Reader) readErr() error {
+	err := reader.err
+	reader.err = nil
+	return err
+}
+
+// Read reads data into p, and returns the number of the bytes read into p.
+// It calls Read of the underlying reader at most once.
+func (reader *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		if reader.Buffered() > 0 {
+			return 0, nil
+		}
+		return 0, reader.readErr()
+	}
+
+	if reader.r == reader.w {
+		if reader.err != nil {
+			return 0, reader.readErr()
+		}
+		if len(p) >= reader.size {
+			// read directly into p, without a buffer.
+			n, err := reader.rd.Read(p)
+			if n < 0 {
+				panic("bytespool: reader returned negative count from Read")
+			}
+			return n, err
+		}
+
+		reader.fill()
+		if reader.r == reader.w {
+			reader.release()
+			return 0, reader.readErr()
+		}
+	}
+
+	n := copy(p, reader.buf[reader.r:reader.w])
+	reader.r += n
+	reader.release()
+	return n, nil
+}
+
+// ReadByte reads and returns a single byte.
+// If no byte is available, returns an error.
+func (reader *Reader) ReadByte() (byte, error) {
+	for reader.r == reader.w {
+		if reader.err != nil {
+			reader.release()
+			return 0, reader.readErr()
+		}
+		reader.fill()
+	}
+
+	c := reader.buf[reader.r]
+	reader.r++
+	reader.release()
+	return c, nil
+}
+
+// ReadRune reads a single UTF-8 encoded Unicode character, and returns the rune and its size in bytes.
+// If the encoded rune is invalid, it consumes one byte and returns unicode.ReplacementChar (U+FFFD) with a size of 1.
+func (reader *Reader) ReadRune() (rune, int, error) {
+	for reader.r+utf8.UTFMax > reader.w && !utf8.FullRune(reader.buf[reader.r:reader.w]) && reader.err == nil && reader.w-reader.r < reader.size {
+		reader.fill()
+	}
+	if reader.r == reader.w {
+		reader.release()
+		return 0, 0, reader.readErr()
+	}
+
+	r, size := rune(reader.buf[reader.r]), 1
+	if r >= utf8.RuneSelf {
+		r, size = utf8.DecodeRune(reader.buf[reader.r:reader.w])
+	}
+	reader.r += size
+	reader.release()
+	return r, size, nil
+}
+
+// Peek returns the next n bytes without advancing the reader.
+// The bytes stop being valid at the next read call.
+// If Peek returns fewer than n bytes, it also returns an error explaining why the read is short.
+// The error is bufio.ErrBufferFull if n is larger than the buffer size.
+func (reader *Reader) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, bufio.ErrNegativeCount
+	}
+
+	for reader.w-reader.r < n && reader.w-reader.r < reader.size && reader.err == nil {
+		reader.fill()
+	}
+
+	if n > reader.size {
+		return reader.buf[reader.r:reader.w], bufio.ErrBufferFull
+	}
+
+	var err error
+	if avail := reader.w - reader.r; avail < n {
+		n = avail
+		err = reader.readErr()
+		if err == nil {
+			err = bufio.ErrBufferFull
+		}
+	}
+	if n == 0 {
+		reader.release()
+		return nil, err
+	}
+	return reader.buf[reader.r : reader.r+n], err
+}
+
+// Discard skips the next n bytes, and returns the number of the discarded bytes.
+// If Discard skips fewer than n bytes, it also returns an error.
+func (reader *Reader) Discard(n int) (int, error) {
+	if n < 0 {
+		return 0, bufio.ErrNegativeCount
+	}
+
+	remain := n
+	for remain > 0 {
+		skip := reader.Buffered()
+		if skip == 0 {
+			if reader.err != nil {
+				reader.release()
+				return n - remain, reader.readErr()
+			}
+			reader.fill()
+			skip = reader.Buffered()
+		}
+		if skip > remain {
+			skip = remain
+		}
+		reader.r += skip
+		remain -= skip
+	}
+	reader.release()
+	return n, nil
+}
+
+// ReadBytes reads until the first occurrence of delim in the input,
+// returning a slice containing the data up to and including the delimiter.
+// If it encounters an error before finding a delimiter,
+// it returns the data read before the error and the error itself, often io.EOF.
+func (reader *Reader) ReadBytes(delim byte) ([]byte, error) {
+	var line []byte
+	for {
+		buffered := reader.buf[reader.r:reader.w]
+		if idx := bytes.IndexByte(buffered, delim); idx >= 0 {
+			line = append(line, buffered[:idx+1]...)
+			reader.r += idx + 1
+			reader.release()
+			return line, nil
+		}
+		line = append(line, buffered...)
+		reader.r = reader.w
+
+		if reader.err != nil {
+			reader.release()
+			return line, reader.readErr()
+		}
+		reader.fill()
+	}
+}
+
+// ReadString reads until the first occurrence of delim in the input,
+// returning a string containing the data up to and including the delimiter.
+// If it encounters an error before finding a delimiter,
+// it returns the data read before the error and the error itself, often io.EOF.
+func (reader *Reader) ReadString(delim byte) (string, error) {
+	line, err := reader.ReadBytes(delim)
+	return string(line), err
+}
+
+// WriteTo writes the buffered bytes and the rest of the underlying reader to w.
+func (reader *Reader) WriteTo(w io.Writer) (int64, error) {
+	var nWrote int64
+	for {
+		if reader.r < reader.w {
+			n, err := w.Write(reader.buf[reader.r:reader.w])
+			if n < 0 || n > reader.w-reader.r {
+				panic("bytespool: writer returned invalid count from Write")
+			}
+			reader.r += n
+			nWrote += int64(n)
+			if err != nil {
+				return nWrote, err
+			}
+			if reader.r < reader.w {
+				return nWrote, io.ErrShortWrite
+			}
+		}
+		reader.release()
+
+		if reader.err != nil {
+			err := reader.readErr()
+			if err == io.EOF {
+				err = nil
+			}
+			return nWrote, err
+		}
+		if writerTo, ok := reader.rd.(io.WriterTo); ok {
+			n, err := writerTo.WriteTo(w)
+			return nWrote + n, err
+		}
+		reader.fill()
+	}
+}
+
+// GetWriter acquires a Writer writing to w, with a buffer of size.
+// If size is not greater than 0, DefaultBufioSize is used.
+func GetWriter(w io.Writer, size int) *Writer {
+	if size <= 0 {
+		size = DefaultBufioSize
+	}
+	writer := writerPool.Get()
+	writer.wr = w
+	writer.size = size
+	return writer
+}
+
+// PutWriter releases the buffer of the writer, and releases the writer.
+// The bytes not flushed are discarded.
+func PutWriter(writer *Writer) {
+	writerPool.Put(writer)
+}
+
+// Writer is a buffered writer like bufio.Writer, with the buffer of a bytes pool.
+// The buffer is acquired by the first write after a flush, and released by the flush,
+// so a idle writer, like the writer of a idle connection, holds no buffer.
+// The writes not smaller than the buffer size bypass the buffer if nothing is buffered.
+// If an error occurs writing to a Writer, no more data will be accepted
+// and all subsequent writes, and Flush, will return the error.
+// A Writer is acquired by GetWriter, or a zero Writer writes with a buffer of DefaultBufioSize after Reset.
+type Writer struct {
+	// BytesPool is a pool of the buffer.
+	// It must not be changed while the writer holds a buffer.
+	// default is DefaultBytesPool.
+	BytesPool SizedBytesPool
+
+	wr   io.Writer
+	size int
+
+	// buf is nil while nothing is buffered.
+	buf []byte
+	n   int
+	err error
+}
+
+func (writer *Writer) pool() SizedBytesPool {
+	if writer.BytesPool == nil {
+		return DefaultBytesPool
+	}
+	return writer.BytesPool
+}
+
+// Size returns the size of the buffer.
+func (writer *Writer) Size() int {
+	return writer.size
+}
+
+// Buffered returns the number of the bytes written into the buffer.
+func (writer *Writer) Buffered() int {
+	return writer.n
+}
+
+// Available returns how many bytes are unused in the buffer.
+func (writer *Writer) Available() int {
+	return writer.size - writer.n
+}
+
+// Reset releases the buffer, discards the bytes not flushed, clears the error,
+// and switches the writer to write to w.
+func (writer *Writer) Reset(w io.Writer) {
+	if writer.buf != nil {
+		writer.pool().Put(writer.buf)
+		writer.buf = nil
+	}
+	if writer.size <= 0 {
+		writer.size = DefaultBufioSize
+	}
+	writer.wr = w
+	writer.n = 0
+	writer.err = nil
+}
+
+// acquire acquires the buffer if it is not held.
+func (writer *Writer) acquire() {
+	if writer.buf == nil {
+		if writer.size <= 0 {
+			writer.size = DefaultBufioSize
+		}
+		writer.buf = writer.pool().Get(writer.size)[:writer.size]
+	}
+}
+
+// Flush writes the buffered bytes to the underlying writer, and releases the buffer.
+func (writer *Writer) Flush() error {
+	if writer.err != nil {
+		return writer.err
+	}
+	if writer.n == 0 {
+		return nil
+	}
+
+	n, err := writer.wr.Write(writer.buf[:writer.n])
+	if n < writer.n && err == nil {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		if n > 0 && n < writer.n {
+			copy(writer.buf[:writer.n-n], writer.buf[n:writer.n])
+		}
+		writer.n -= n
+		writer.err = err
+		return err
+	}
+
+	writer.n = 0
+	writer.pool().Put(writer.buf)
+	writer.buf = nil
+	return nil
+}
+
+// Write writes the contents of p into the buffer.
+// It returns the number of bytes written, and a error explaining why if it is less than len(p).
+func (writer *Writer) Write(p []byte) (int, error) {
+	var nWrote int
+	for len(p) > writer.Available() && writer.err == nil {
+		var n int
+		if writer.n == 0 {
+			// write directly from p, without a buffer.
+			n, writer.err = writer.wr.Write(p)
+		} else {
+			n = copy(writer.buf[writer.n:], p)
+			writer.n += n
+			writer.Flush()
+		}
+		nWrote += n
+		p = p[n:]
+	}
+	if writer.err != nil {
+		return nWrote, writer.err
+	}
+	if len(p) == 0 {
+		return nWrote, nil
+	}
+
+	writer.acquire()
+	n := copy(writer.buf[writer.n:], p)
+	writer.n += n
+	nWrote += n
+	return nWrote, nil
+}
+
+// WriteString writes the contents of str into the buffer.
+// It returns the number of bytes written, and a error explaining why if it is less than len(str).
+func (writer *Writer) WriteString(str string) (int, error) {
+	var nWrote int
+	for len(str) > writer.Available() && writer.err == nil {
+		writer.acquire()
+		n := copy(writer.buf[writer.n:], str)
+		writer.n += n
+		nWrote += n
+		str = str[n:]
+		writer.Flush()
+	}
+	if writer.err != nil {
+		return nWrote, writer.err
+	}
+	if len(str) == 0 {
+		return nWrote, nil
+	}
+
+	writer.acquire()
+	n := copy(writer.buf[writer.n:], str)
+	writer.n += n
+	nWrote += n
+	return nWrote, nil
+}
+
+// WriteByte writes a single byte.
+func (writer *Writer) WriteByte(c byte) error {
+	if writer.err != nil {
+		return writer.err
+	}
+	if writer.Available() <= 0 && writer.Flush() != nil {
+		return writer.err
+	}
+
+	writer.acquire()
+	writer.buf[writer.n] = c
+	writer.n++
+	return nil
+}
+
+// WriteRune writes a single Unicode code point, returning the number of bytes written and any error.
+func (writer *Writer) WriteRune(r rune) (int, error) {
+	if uint32(r) < utf8.RuneSelf {
+		err := writer.WriteByte(byte(r))
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	if writer.Available() < utf8.UTFMax {
+		if writer.Flush(); writer.err != nil {
+			return 0, writer.err
+		}
+		if writer.Available() < utf8.UTFMax {
+			// the buffer is too small for the rune.
+			var runeBytes [utf8.UTFMax]byte
+			n := utf8.EncodeRune(runeBytes[:], r)
+			return writer.Write(runeBytes[:n])
+		}
+	}
+
+	writer.acquire()
+	n := utf8.EncodeRune(writer.buf[writer.n:], r)
+	writer.n += n
+	return n, nil
+}
+
+// ReadFrom reads data from r until EOF into the buffer, flushing the full buffer.
+// If nothing is buffered and the underlying writer supports ReadFrom, it calls the underlying ReadFrom.
+func (writer *Writer) ReadFrom(r io.Reader) (int64, error) {
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	if readerFrom, ok := writer.wr.(io.ReaderFrom); ok && writer.n == 0 {
+		return readerFrom.ReadFrom(r)
+	}
+
+	var nRead int64
+	for {
+		if writer.Available() == 0 {
+			if err := writer.Flush(); err != nil {
+				return nRead, err
+			}
+		}
+
+		writer.acquire()
+		var n int
+		var err error
+		for i := maxConsecutiveEmptyReads; i > 0; i-- {
+			n, err = r.Read(writer.buf[writer.n:])
+			if n != 0 || err != nil {
+				break
+			}
+			if i == 1 {
+				err = io.ErrNoProgress
+			}
+		}
+		writer.n += n
+		nRead += int64(n)
+
+		if err != nil {
+			if writer.n == 0 {
+				// nothing is buffered, like the reads to the idle connection.
+				writer.pool().Put(writer.buf)
+				writer.buf = nil
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			return nRead, err
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package bytespool
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	rand.Seed(time.Now().Unix())
+	data := make([]byte, 100*1024+rand.Intn(1024))
+	rand.Read(data)
+
+	for _, r := range []io.Reader{
+		bytes.NewReader(data),
+		iotest.OneByteReader(bytes.NewReader(data)),
+		iotest.HalfReader(bytes.NewReader(data)),
+		iotest.DataErrReader(bytes.NewReader(data)),
+	} {
+		pool := &BytesPool{}
+		reader := GetReader(r, 1024)
+		reader.BytesPool = pool
+
+		err := iotest.TestReader(reader, data)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+			t.Fatalf("the drained buffer should be released, outstanding: %d", outstanding)
+			return
+		}
+		PutReader(reader)
+	}
+}
+
+func TestReaderMethods(t *testing.T) {
+	pool := &BytesPool{}
+	reader := GetReader(iotest.HalfReader(strings.NewReader("hello, 世界\nfoo\nbar")), 16)
+	reader.BytesPool = pool
+	defer PutReader(reader)
+
+	peeked, err := reader.Peek(5)
+	if err != nil || string(peeked) != "hello" {
+		t.Fatalf("peek error, have: %q, %v", peeked, err)
+		return
+	}
+	if _, err = reader.Peek(17); err != bufio.ErrBufferFull {
+		t.Fatalf("peek error, want: %v, have: %v", bufio.ErrBufferFull, err)
+		return
+	}
+	if n, err := reader.Discard(7); n != 7 || err != nil {
+		t.Fatalf("discard error, have: %d, %v", n, err)
+		return
+	}
+	if r, size, err := reader.ReadRune(); r != '世' || size != 3 || err != nil {
+		t.Fatalf("read rune error, have: %c, %d, %v", r, size, err)
+		return
+	}
+	line, err := reader.ReadString('\n')
+	if line != "界\n" || err != nil {
+		t.Fatalf("read string error, have: %q, %v", line, err)
+		return
+	}
+	if c, err := reader.ReadByte(); c != 'f' || err != nil {
+		t.Fatalf("read byte error, have: %c, %v", c, err)
+		return
+	}
+
+	var writer bytes.Buffer
+	n, err := reader.WriteTo(&writer)
+	if n != 6 || err != nil || writer.String() != "oo\nbar" {
+		t.Fatalf("write to error, have: %d, %v, %q", n, err, writer.String())
+		return
+	}
+	if _, err = reader.ReadByte(); err != io.EOF {
+		t.Fatalf("read byte error, want: %v, have: %v", io.EOF, err)
+		return
+	}
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("the drained buffer should be released, outstanding: %d", outstanding)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	rand.Seed(time.Now().Unix())
+	data := make([]byte, 100*1024+rand.Intn(1024))
+	rand.Read(data)
+
+	pool := &BytesPool{}
+	var have bytes.Buffer
+	writer := GetWriter(&have, 1024)
+	writer.BytesPool = pool
+	defer PutWriter(writer)
+
+	for p := data; len(p) > 0; {
+		n := rand.Intn(3000) + 1
+		if n > len(p) {
+			n = len(p)
+		}
+		var err error
+		switch rand.Intn(3) {
+		case 0:
+			_, err = writer.Write(p[:n])
+		case 1:
+			_, err = writer.WriteString(string(p[:n]))
+		case 2:
+			n = 1
+			err = writer.WriteByte(p[0])
+		}
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		p = p[n:]
+	}
+
+	err := writer.Flush()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !bytes.Equal(have.Bytes(), data) {
+		t.Fatal("written data missmatch")
+		return
+	}
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("the flushed buffer should be released, outstanding: %d", outstanding)
+	}
+}
+
+func TestWriterReadFrom(t *testing.T) {
+	pool := &BytesPool{}
+	var have strings.Builder
+	writer := GetWriter(&have, 16)
+	writer.BytesPool = pool
+	defer PutWriter(writer)
+
+	writer.WriteRune('世')
+	n, err := writer.ReadFrom(iotest.HalfReader(strings.NewReader("hello, world")))
+	if n != 12 || err != nil {
+		t.Fatalf("read from error, have: %d, %v", n, err)
+		return
+	}
+	if writer.Buffered() == 0 || pool.Stats().OutstandingBytes() == 0 {
+		t.Fatal("the bytes should be buffered until flush")
+		return
+	}
+
+	writer.Flush()
+	if have.String() != "世hello, world" {
+		t.Fatalf("written data error, have: %q", have.String())
+		return
+	}
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("the flushed buffer should be released, outstanding: %d", outstanding)
+	}
+}
+
+func TestWriterError(t *testing.T) {
+	writer := GetWriter(&throttledWriter{limit: 100, fail: 1, err: io.ErrClosedPipe}, 16)
+	defer PutWriter(writer)
+
+	writer.WriteString("0123456789abcdef0123")
+	if err := writer.Flush(); err != io.ErrClosedPipe {
+		t.Fatalf("flush error, want: %v, have: %v", io.ErrClosedPipe, err)
+		return
+	}
+	if _, err := writer.WriteString("x"); err != io.ErrClosedPipe {
+		t.Fatalf("write error, want: %v, have: %v", io.ErrClosedPipe, err)
+	}
+}
+
+func TestBufioZeroValue(t *testing.T) {
+	var have strings.Builder
+	var writer Writer
+	writer.Reset(&have)
+	if writer.Size() != DefaultBufioSize {
+		t.Fatalf("size error, want: %d, have: %d", DefaultBufioSize, writer.Size())
+		return
+	}
+	writer.WriteByte('h')
+	writer.WriteString("ello")
+	if err := writer.Flush(); err != nil || have.String() != "hello" {
+		t.Fatalf("written data error, have: %q, %v", have.String(), err)
+		return
+	}
+
+	var reader Reader
+	reader.Reset(strings.NewReader(have.String()))
+	if reader.Size() != DefaultBufioSize {
+		t.Fatalf("size error, want: %d, have: %d", DefaultBufioSize, reader.Size())
+		return
+	}
+	if r, _, err := reader.ReadRune(); r != 'h' || err != nil {
+		t.Fatalf("read rune error, have: %c, %v", r, err)
+		return
+	}
+	if peeked, err := reader.Peek(4); string(peeked) != "ello" || err != nil {
+		t.Fatalf("peek error, have: %q, %v", peeked, err)
+	}
+}
@@ -1,13 +1,14 @@
 package bytespool
 
 import (
-	"fmt"
-	"math"
+	"context"
+	"errors"
 	"sync"
+	"sync/atomic"
 )
 
 const (
-	// indexLength maximum length of the index
+	// indexLength number of the default size classes
 	indexLength = 1034
 
 	// littleCapacityUpper upper limit of the capacity of little bytes
@@ -16,18 +17,26 @@ const (
 	// largeCapacityUpper upper limit of the capacity of large bytes
 	largeCapacityUpper = 1024 * 1024
 
-	// littleIndexUpper maximum length of the index of little bytes
+	// littleIndexUpper maximum index of little bytes
 	littleIndexUpper = 10
 )
 
 var (
+	// ErrForeignBytes means the capacity of the bytes is not of any size class of the pool.
+	ErrForeignBytes = errors.New("bytespool: capacity of the bytes is not of any size class")
+
 	// DefaultBytesPool is the default instance of BytesPool.
 	DefaultBytesPool = &BytesPool{}
 
 	// EmptyBytes represents empty bytes
 	EmptyBytes = make([]byte, 0)
 
+	// DefaultLargeRetention is the default value of BytesPool.LargeRetention.
+	DefaultLargeRetention = 4
+
 	// DefaultSizedBytesPoolFactory is a default factory for producing SizedBytesPool instance.
+	// The produced pool allocates the bytes by New if it is empty,
+	// BytesPool counts the calls of New as fresh allocations.
 	DefaultSizedBytesPoolFactory = func(size int) Pool {
 		return &sync.Pool{
 			New: func() interface{} {
@@ -37,6 +46,13 @@ var (
 	}
 )
 
+// emptySizedPoolFactory produces a sync.Pool that returns nil if it is empty,
+// so BytesPool allocates and counts the fresh bytes.
+// It is the default factory of BytesPool and SlicePool.
+func emptySizedPoolFactory(size int) Pool {
+	return &sync.Pool{}
+}
+
 // GetBytes is a quick method for DefaultBytesPool.Get.
 func GetBytes(length int) []byte {
 	return DefaultBytesPool.Get(length)
@@ -48,118 +64,545 @@ func PutBytes(bytes []byte) {
 }
 
 // SizedBytesPoolFactory creates the pool for the bytes that length is fixed.
+// The Get of the produced pool may return nil if the pool is empty,
+// then BytesPool allocates a new bytes and counts it as a fresh allocation.
+// If the produced pool is a sync.Pool with New, the calls of New are counted as fresh allocations too.
+// The bytes allocated by the other pools themselves are not counted.
 type SizedBytesPoolFactory func(length int) Pool
 
+// ForeignPolicy decides what BytesPool.Put does with the bytes
+// that capacity is not of any size class.
+type ForeignPolicy int
+
+const (
+	// ForeignDrop drops the bytes.
+	ForeignDrop ForeignPolicy = iota
+
+	// ForeignRebucket reslices the bytes to the capacity of the largest size class
+	// it can hold, and releases it to the size class.
+	// The capacity beyond the size class is not reused.
+	ForeignRebucket
+
+	// ForeignPanic panics with ErrForeignBytes.
+	ForeignPanic
+)
+
+// sizeClass is the pool of a size class, its counters are in the counters of BytesPool.
+type sizeClass struct {
+	pool     Pool
+	capacity int
+}
+
 // BytesPool represents bytes pool
 type BytesPool struct {
 	// SizedPoolFactory is a factory for producing SizedBytesPool instance.
-	// default is DefaultSizedBytesPoolFactory.
+	// default produces a sync.Pool that returns nil if it is empty.
 	SizedPoolFactory SizedBytesPoolFactory
 
-	pools       [indexLength]Pool
-	capacities  [indexLength]int
-	newPoolMutx sync.Mutex
+	// SizeClasses is the ascending capacities of the size classes.
+	// A length is served by the smallest size class which can hold it,
+	// a length larger than the largest size class is not pooled.
+	// It takes effect at the first use of the pool.
+	// default is DefaultSizeClasses.
+	SizeClasses []int
+
+	// LargeCapacityCeiling adds the power of two size classes
+	// above the largest one of SizeClasses, up to the ceiling.
+	// It takes effect at the first use of the pool.
+	// default is 0, no size class is added.
+	LargeCapacityCeiling int
+
+	// ForeignPolicy decides what Put does with the bytes
+	// that capacity is not of any size class.
+	// It is ignored if MaxOutstandingBytes is set, the bytes are dropped.
+	// default is ForeignDrop.
+	ForeignPolicy ForeignPolicy
+
+	// Debug enables the debug checks.
+	// default is 0, no check.
+	Debug DebugFlags
+
+	// LeakTracker records the acquired bytes until they are released.
+	// The bytes reserved by a idle Buffer are reported as outstanding too.
+	// default is nil, no record.
+	LeakTracker *LeakTracker
+
+	// LargePoolFactory is a factory for producing the pools
+	// of the size classes larger than 1MiB.
+	// default produces the BoundedPool that keeps at most LargeRetention idle bytes.
+	LargePoolFactory SizedBytesPoolFactory
+
+	// LargeRetention is the maximum number of the idle bytes kept
+	// by each size class larger than 1MiB, if LargePoolFactory is nil.
+	// default is DefaultLargeRetention.
+	LargeRetention int
+
+	// MaxIdleBytes is the maximum capacity of the idle bytes of all size classes.
+	// Put drops the bytes beyond it.
+	// The count is approximate, as the pools may drop the idle bytes silently, like sync.Pool.
+	// It should be set before the pool is used.
+	// default is 0, unlimited.
+	MaxIdleBytes int64
+
+	// MaxOutstandingBytes is the maximum capacity of the bytes acquired and not yet released.
+	// Get behaves as BudgetMode if it is reached.
+	// It should be set before the pool is used.
+	// default is 0, unlimited.
+	MaxOutstandingBytes int64
+
+	// BudgetMode decides what Get does if MaxOutstandingBytes is reached.
+	// default is BudgetFallback.
+	BudgetMode BudgetMode
+
+	// ZeroOnPut zeroes the bytes up to the capacity when they are released,
+	// so no data leaks to the next user of the bytes.
+	// It costs a memclr of the capacity for each Put.
+	// default is false.
+	ZeroOnPut bool
+
+	// Alignment is the alignment of the start of the bytes acquired by Get, a power of two.
+	// It is also the minimum alignment of the newly allocated bytes,
+	// so a pool dedicated to GetAligned avoids dropping the misaligned bytes.
+	// It should be set before the pool is used.
+	// default is 0, no alignment.
+	Alignment int
+
+	initOnce     sync.Once
+	table        sizeClasses
+	classes      []sizeClass
+	counters     counters
+	oversizeGets atomic.Uint64
+	newPoolMutx  sync.Mutex
+	debugger     debugger
+	idleBytes    atomic.Int64
+	budget       budget
+
+	// oversizes is the capacities of the oversize bytes counted in the budget, keyed by the array pointers.
+	// They are at most MaxOutstandingBytes divided by the largest size class.
+	oversizes sync.Map
+}
+
+func (pool *BytesPool) init() {
+	pool.initOnce.Do(func() {
+		capacities := pool.SizeClasses
+		if capacities == nil {
+			capacities = DefaultSizeClasses
+		}
+		if pool.LargeCapacityCeiling > 0 && len(capacities) > 0 {
+			large := largeSizeClasses(capacities[len(capacities)-1], pool.LargeCapacityCeiling)
+			capacities = append(append([]int{}, capacities...), large...)
+		}
+		pool.table = newSizeClasses(capacities)
+		pool.classes = make([]sizeClass, len(pool.table))
+		for idx, capacity := range pool.table {
+			pool.classes[idx].capacity = capacity
+		}
+		pool.counters.init(len(pool.table))
+	})
 }
 
 func (pool *BytesPool) getCapacity(idx int) int {
-	if idx <= littleIndexUpper {
-		return int(math.Pow(2, float64(idx)))
-	} else if idx < indexLength {
-		return 1024 * (idx - littleIndexUpper + 1)
-	}
-	panic(fmt.Sprintf("index must be smaller than %d", indexLength))
+	pool.init()
+	return pool.table.getCapacity(idx)
 }
 
 func (pool *BytesPool) getIndex(length int) int {
-	if length <= littleCapacityUpper {
-		return int(math.Ceil(math.Log2(float64(length))))
-	} else if length <= largeCapacityUpper {
-		return int(math.Ceil(float64(length)/1024)) + littleIndexUpper - 1
-	}
-	panic(fmt.Sprintf("length must be smaller or equal than %d", largeCapacityUpper))
+	pool.init()
+	return pool.table.getIndex(length)
 }
 
 func (pool *BytesPool) findIndex(capacity int) (int, bool) {
-	if capacity > largeCapacityUpper {
-		return 0, false
-	} else if capacity <= 0 {
-		return 0, false
-	} else if capacity <= littleCapacityUpper {
-		log2 := math.Log2(float64(capacity))
-		idx := int(log2)
-		if log2 != float64(idx) {
-			return 0, false
-		}
-		return idx, true
-	} else { // littleCapacityUpper < capacity <= largeCapacityUpper
-		log := float64(capacity) / 1024
-		idx := int(log)
-		if log != float64(idx) {
-			return 0, false
-		}
-		return idx + littleIndexUpper - 1, true
-	}
+	pool.init()
+	return pool.table.findIndex(capacity)
 }
 
 func (pool *BytesPool) getPool(idx int) Pool {
-	p := pool.pools[idx]
+	class := &pool.classes[idx]
+	p := class.pool
 	if p == nil {
 		pool.newPoolMutx.Lock()
 		defer pool.newPoolMutx.Unlock()
 
-		p = pool.pools[idx]
+		p = class.pool
 		if p == nil {
 			capacity := pool.getCapacity(idx)
 
-			if pool.SizedPoolFactory == nil {
-				pool.SizedPoolFactory = DefaultSizedBytesPoolFactory
+			if capacity > largeCapacityUpper {
+				p = pool.newLargePool(capacity)
+			} else {
+				factory := pool.SizedPoolFactory
+				if factory == nil {
+					factory = emptySizedPoolFactory
+				}
+				p = factory(capacity)
 			}
-			p = pool.SizedPoolFactory(capacity)
+			pool.countNew(idx, p)
 
-			pool.pools[idx] = p
-			pool.capacities[idx] = capacity
+			class.pool = p
 		}
 	}
 	return p
 }
 
-func (pool *BytesPool) acquireBytes(length int) []byte {
-	idx := pool.getIndex(length)
+// countNew counts the calls of New of a sync.Pool as the fresh allocations of the size class,
+// as the pool never returns nil to BytesPool.
+func (pool *BytesPool) countNew(idx int, p Pool) {
+	syncPool, ok := p.(*sync.Pool)
+	if !ok || syncPool.New == nil {
+		return
+	}
+
+	newFunc := syncPool.New
+	syncPool.New = func() interface{} {
+		x := newFunc()
+		if bytes, ok := x.([]byte); ok {
+			pool.counters.stripeOf(arrayPointer(bytes))[idx].news.Add(1)
+		}
+		return x
+	}
+}
+
+func (pool *BytesPool) newLargePool(capacity int) Pool {
+	if pool.LargePoolFactory != nil {
+		return pool.LargePoolFactory(capacity)
+	}
+	if pool.LargeRetention == 0 {
+		pool.LargeRetention = DefaultLargeRetention
+	}
+	return NewBoundedPool(pool.LargeRetention)
+}
+
+// acquireBytes acquires the bytes of the size class, which start is aligned to alignment if it is greater than 0.
+func (pool *BytesPool) acquireBytes(idx int, alignment int) []byte {
 	p := pool.getPool(idx)
-	return p.Get().([]byte)
+	class := &pool.classes[idx]
+
+	x := p.Get()
+	if x != nil && alignment > 0 && arrayPointer(x.([]byte))%uintptr(alignment) != 0 {
+		// drop the misaligned bytes, so the pool fills with the aligned ones.
+		stripe := pool.counters.stripeOf(arrayPointer(x.([]byte)))
+		if pool.counters.takeIdle(stripe, idx) && pool.MaxIdleBytes > 0 {
+			pool.idleBytes.Add(-int64(class.capacity))
+		}
+		discard(p, x.([]byte))
+		x = nil
+	}
+	if x == nil {
+		// the pool is empty, whatever the count is.
+		idle := pool.counters.resetIdle(idx)
+		if pool.MaxIdleBytes > 0 {
+			pool.idleBytes.Add(-idle * int64(class.capacity))
+		}
+
+		bytes := pool.allocateClass(idx, alignment)
+		counters := &pool.counters.stripeOf(arrayPointer(bytes))[idx]
+		counters.gets.Add(1)
+		counters.news.Add(1)
+		if pool.Debug != 0 {
+			pool.debugger.get(bytes, pool.Debug, true)
+		}
+		return bytes
+	}
+	bytes := x.([]byte)
+	stripe := pool.counters.stripeOf(arrayPointer(bytes))
+	stripe[idx].gets.Add(1)
+	if pool.counters.takeIdle(stripe, idx) && pool.MaxIdleBytes > 0 {
+		pool.idleBytes.Add(-int64(class.capacity))
+	}
+
+	if pool.Debug != 0 {
+		pool.debugger.get(bytes, pool.Debug, false)
+		if pool.ZeroOnPut && pool.Debug&DebugPoison != 0 {
+			// the poison replaced the zeroes
+			zero(bytes[:cap(bytes)])
+		}
+	}
+	return bytes
+}
+
+// zero sets the bytes to zero.
+// The loop is compiled to a memclr, the same as the builtin clear.
+func zero(bytes []byte) {
+	for idx := range bytes {
+		bytes[idx] = 0
+	}
+}
+
+// allocate allocates a bytes without pooling,
+// which start is aligned to alignment, or Alignment of the pool.
+func (pool *BytesPool) allocate(capacity int, alignment int) []byte {
+	if alignment < pool.Alignment {
+		alignment = pool.Alignment
+	}
+	if alignment <= 1 {
+		return make([]byte, 0, capacity)
+	}
+
+	bytes := make([]byte, capacity+alignment-1)
+	offset := int(uintptr(alignment)-arrayPointer(bytes)%uintptr(alignment)) % alignment
+	return bytes[offset : offset : offset+capacity]
+}
+
+// allocateClass allocates a new bytes of the size class,
+// by the pool of the class if it is a BytesAllocator.
+func (pool *BytesPool) allocateClass(idx int, alignment int) []byte {
+	if allocator, ok := pool.getPool(idx).(BytesAllocator); ok {
+		if alignment < pool.Alignment {
+			alignment = pool.Alignment
+		}
+		return allocator.AllocateBytes(alignment)
+	}
+	return pool.allocate(pool.classes[idx].capacity, alignment)
 }
 
 // Get acquire a slice with a len of length and a capacity of at least length.
+// If MaxOutstandingBytes is reached, it behaves as BudgetMode,
+// and panics with ErrBudgetExceeded in BudgetFail mode.
 func (pool *BytesPool) Get(length int) []byte {
-	var bytes []byte
-	if length > largeCapacityUpper {
-		bytes = make([]byte, 0, length)
-	} else if length == 0 {
-		bytes = EmptyBytes
-	} else if length < 0 {
+	bytes, err := pool.GetContext(context.Background(), length)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// GetContext acquire a slice with a len of length and a capacity of at least length.
+// If MaxOutstandingBytes is reached, it returns ErrBudgetExceeded in BudgetFail mode,
+// or returns the error of the context in BudgetBlock mode if the context is done before enough bytes are released.
+func (pool *BytesPool) GetContext(ctx context.Context, length int) ([]byte, error) {
+	return pool.getContext(ctx, length, pool.Alignment)
+}
+
+// GetZeroed acquire a slice with a len of length and a capacity of at least length,
+// which bytes up to length are zero.
+// If ZeroOnPut is false, it costs a memclr of the length.
+func (pool *BytesPool) GetZeroed(length int) []byte {
+	bytes := pool.Get(length)
+	if !pool.ZeroOnPut {
+		zero(bytes)
+	}
+	return bytes
+}
+
+// GetAligned acquire a slice with a len of length and a capacity of at least length,
+// which start is aligned to alignment, a power of two.
+// The bytes keep the alignment through reuse, as the misaligned bytes acquired from the pool are dropped.
+// The new bytes of a BytesAllocator pool, like the pools of MmapArena, are aligned by the pool.
+func (pool *BytesPool) GetAligned(length, alignment int) []byte {
+	bytes, err := pool.GetAlignedContext(context.Background(), length, alignment)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// GetAlignedContext is like GetAligned, but returns the error like GetContext if MaxOutstandingBytes is reached.
+func (pool *BytesPool) GetAlignedContext(ctx context.Context, length, alignment int) ([]byte, error) {
+	if alignment <= 0 || alignment&(alignment-1) != 0 {
+		panic("alignment must be a power of two")
+	}
+	return pool.getContext(ctx, length, alignment)
+}
+
+func (pool *BytesPool) getContext(ctx context.Context, length int, alignment int) ([]byte, error) {
+	pool.init()
+
+	if length < 0 {
 		panic("length must be greater than 0")
+	} else if length == 0 {
+		return EmptyBytes, nil
+	}
+
+	oversize := length > pool.table.maxCapacity()
+	var idx int
+	capacity := length
+	if !oversize {
+		idx = pool.table.getIndex(length)
+		capacity = pool.classes[idx].capacity
+	}
+
+	if pool.MaxOutstandingBytes > 0 {
+		counted, err := pool.budget.acquire(ctx, capacity, pool.MaxOutstandingBytes, pool.BudgetMode)
+		if err != nil {
+			return nil, err
+		}
+		if !counted {
+			return pool.allocateFallback(length, alignment), nil
+		}
+	}
+
+	var bytes []byte
+	if oversize {
+		pool.oversizeGets.Add(1)
+		bytes = pool.allocate(length, alignment)
+		if pool.MaxOutstandingBytes > 0 {
+			pool.oversizes.Store(arrayPointer(bytes), capacity)
+		}
 	} else {
-		bytes = pool.acquireBytes(length)
+		bytes = pool.acquireBytes(idx, alignment)
+	}
+
+	if pool.LeakTracker != nil {
+		pool.LeakTracker.track(arrayPointer(bytes), "bytes", cap(bytes))
 	}
+	return bytes[:length], nil
+}
 
-	return bytes[:length]
+// allocateFallback allocates the bytes beyond the budget without pooling and without counting them.
+// The capacity is not of any size class,
+// so Put drops them without touching the budget or the debug checks.
+func (pool *BytesPool) allocateFallback(length int, alignment int) []byte {
+	capacity := length
+	for {
+		if _, found := pool.table.findIndex(capacity); !found {
+			break
+		}
+		capacity++
+	}
+
+	return pool.allocate(capacity, alignment)[:length]
+}
+
+// isOversize reports whether the oversize bytes was counted in the budget, and forgets it.
+func (pool *BytesPool) isOversize(bytes []byte) bool {
+	ptr := arrayPointer(bytes)
+	capacity, ok := pool.oversizes.Load(ptr)
+	if !ok || capacity.(int) != cap(bytes) {
+		return false
+	}
+	pool.oversizes.Delete(ptr)
+	return true
 }
 
 // Put reset and release a bytes slice.
+// The bytes that capacity is not of any size class are handled by ForeignPolicy.
+// In DebugTrackPuts mode, it panics if the bytes was already released or was never acquired.
 func (pool *BytesPool) Put(bytes []byte) {
+	err := pool.TryPut(bytes)
+	if err == nil {
+		return
+	}
+	if err != ErrForeignBytes {
+		panic(err)
+	}
+
+	switch pool.ForeignPolicy {
+	case ForeignRebucket:
+		idx := pool.table.getIndex(cap(bytes))
+		if pool.classes[idx].capacity > cap(bytes) {
+			idx--
+		}
+		if idx >= 0 {
+			// the foreign bytes are adopted, the error is impossible.
+			_ = pool.release(idx, bytes[:0:pool.classes[idx].capacity], true)
+		}
+	case ForeignPanic:
+		panic(err)
+	}
+}
+
+// TryPut reset and release a bytes slice.
+// If the capacity of the bytes is not of any size class, it returns ErrForeignBytes and drops the bytes,
+// or drops them without error if MaxOutstandingBytes is set, as they may be allocated beyond the budget.
+// In DebugTrackPuts mode, it returns ErrDoublePut or ErrNotFromPool and drops the bytes.
+// The bytes larger than the largest size class are dropped without error,
+// as Get allocates them without pooling.
+// They release the budget of MaxOutstandingBytes only if they were acquired from the pool.
+func (pool *BytesPool) TryPut(bytes []byte) error {
 	capacity := cap(bytes)
+	if capacity == 0 {
+		return nil
+	}
+	if pool.LeakTracker != nil {
+		pool.LeakTracker.untrack(arrayPointer(bytes))
+	}
+
 	idx, found := pool.findIndex(capacity)
 	if !found {
-		return
+		if capacity > pool.table.maxCapacity() {
+			if pool.MaxOutstandingBytes > 0 && pool.isOversize(bytes) {
+				pool.budget.release(capacity)
+			}
+			return nil
+		}
+		if pool.MaxOutstandingBytes > 0 {
+			// may be the fallback bytes.
+			return nil
+		}
+		return ErrForeignBytes
+	}
+
+	return pool.release(idx, bytes, false)
+}
+
+// release releases the bytes to the size class.
+// foreign represents the bytes was never acquired from the pool.
+func (pool *BytesPool) release(idx int, bytes []byte, foreign bool) error {
+	if pool.Debug != 0 {
+		err := pool.debugger.put(bytes, pool.Debug, foreign)
+		if err != nil {
+			return err
+		}
+	}
+	// the poison is replaced by zeroes when the bytes is acquired.
+	if pool.ZeroOnPut && pool.Debug&DebugPoison == 0 {
+		zero(bytes[:cap(bytes)])
 	}
 
-	if capacity != pool.capacities[idx] {
-		panic(fmt.Sprintf("capacity missmatch, want: %d, have: %d, index: %d", pool.capacities[idx], capacity, idx))
+	pool.counters.stripeOf(arrayPointer(bytes))[idx].puts.Add(1)
+	if pool.MaxOutstandingBytes > 0 && !foreign {
+		pool.budget.release(pool.classes[idx].capacity)
 	}
 
+	pool.keep(idx, bytes)
+	return nil
+}
+
+// keep puts the bytes to the pool of the size class, if MaxIdleBytes is not reached.
+func (pool *BytesPool) keep(idx int, bytes []byte) bool {
+	class := &pool.classes[idx]
 	p := pool.getPool(idx)
+	if pool.MaxIdleBytes > 0 {
+		if pool.idleBytes.Add(int64(class.capacity)) > pool.MaxIdleBytes {
+			pool.idleBytes.Add(-int64(class.capacity))
+			discard(p, bytes)
+			return false
+		}
+	}
+
 	bytes = bytes[:0]
 	p.Put(bytes)
+	pool.counters.stripeOf(arrayPointer(bytes))[idx].idle.Add(1)
+	return true
+}
+
+// discard drops the bytes of the pool, by Discard if the pool is a Discarder.
+func discard(p Pool, bytes []byte) {
+	if discarder, ok := p.(Discarder); ok {
+		discarder.Discard(bytes[:0])
+	}
+}
+
+// Prewarm allocates the idle bytes for the lengths in advance.
+// The key of sizes is a length, and the value is the number of the bytes.
+// The lengths larger than the largest size class are ignored.
+// It works with any SizedPoolFactory, but the bytes in a sync.Pool do not survive garbage collections.
+// The bytes of a BytesAllocator pool, like the pools of MmapArena, are allocated by the pool.
+func (pool *BytesPool) Prewarm(sizes map[int]int) {
+	pool.init()
+
+	for length, count := range sizes {
+		if length <= 0 || length > pool.table.maxCapacity() {
+			continue
+		}
+
+		idx := pool.table.getIndex(length)
+		for i := 0; i < count; i++ {
+			if !pool.keep(idx, pool.allocateClass(idx, 0)) {
+				break
+			}
+		}
+	}
 }
 
 func (pool *BytesPool) Reset() {
@@ -2,6 +2,8 @@ package bytespool
 
 import (
 	"math/rand"
+	"runtime"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -92,3 +94,329 @@ func TestBytesReuse(t *testing.T) {
 		return
 	}
 }
+
+func TestBytesStats(t *testing.T) {
+	var pool BytesPool
+
+	bytes1 := pool.Get(100)
+	bytes2 := pool.Get(100)
+	pool.Put(bytes1)
+	bytes3 := pool.Get(100)
+	_ = pool.Get(largeCapacityUpper + 1)
+
+	stats := pool.Stats()
+	if len(stats.Classes) != indexLength {
+		t.Fatalf("classes length error, want: %d, have: %d", indexLength, len(stats.Classes))
+		return
+	}
+	class := stats.Classes[pool.getIndex(100)]
+	if class.Capacity != 128 {
+		t.Fatalf("capacity error, want: %d, have: %d", 128, class.Capacity)
+		return
+	}
+	if class.Gets != 3 || class.Puts != 1 {
+		t.Fatalf("counter error, want: %d gets and %d puts, have: %d gets and %d puts", 3, 1, class.Gets, class.Puts)
+		return
+	}
+	if class.News < 2 || class.News > 3 {
+		t.Fatalf("news error, want: 2 or 3, have: %d", class.News)
+		return
+	}
+	if class.OutstandingBytes() != 2*128 {
+		t.Fatalf("outstanding bytes error, want: %d, have: %d", 2*128, class.OutstandingBytes())
+		return
+	}
+	if stats.OversizeGets != 1 {
+		t.Fatalf("oversize gets error, want: %d, have: %d", 1, stats.OversizeGets)
+		return
+	}
+
+	pool.Put(bytes2)
+	pool.Put(bytes3)
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("outstanding bytes error, want: %d, have: %d", 0, outstanding)
+	}
+}
+
+func TestDefaultSizeClasses(t *testing.T) {
+	var pool BytesPool
+	for _, c := range []struct {
+		length   int
+		capacity int
+	}{
+		{1, 1}, {3, 4}, {100, 128}, {littleCapacityUpper, littleCapacityUpper},
+		{littleCapacityUpper + 1, 2048}, {3000, 3072}, {largeCapacityUpper, largeCapacityUpper},
+	} {
+		capacity := pool.getCapacity(pool.getIndex(c.length))
+		if capacity != c.capacity {
+			t.Fatalf("capacity error, length: %d, want: %d, have: %d", c.length, c.capacity, capacity)
+			return
+		}
+	}
+}
+
+func TestCustomSizeClasses(t *testing.T) {
+	pool := BytesPool{
+		SizeClasses: []int{100, 1000, 10000},
+	}
+	for _, c := range []struct {
+		length   int
+		capacity int
+	}{
+		{1, 100}, {100, 100}, {101, 1000}, {10000, 10000}, {10001, 10001},
+	} {
+		bytes := pool.Get(c.length)
+		if len(bytes) != c.length || cap(bytes) != c.capacity {
+			t.Fatalf("bytes error, want: len %d cap %d, have: len %d cap %d", c.length, c.capacity, len(bytes), cap(bytes))
+			return
+		}
+		pool.Put(bytes)
+	}
+
+	if _, found := pool.findIndex(500); found {
+		t.Fatal("capacity 500 should not be found")
+		return
+	}
+	if idx, found := pool.findIndex(1000); !found || idx != 1 {
+		t.Fatalf("index error, want: %d, have: %d", 1, idx)
+	}
+}
+
+func TestGeometricSizeClasses(t *testing.T) {
+	capacities := GeometricSizeClasses(1, 100, 1.25)
+	if capacities[0] != 1 || capacities[len(capacities)-1] != 100 {
+		t.Fatalf("range error, have: %v", capacities)
+		return
+	}
+	for idx := 1; idx < len(capacities); idx++ {
+		if capacities[idx] <= capacities[idx-1] {
+			t.Fatalf("capacities must be ascending, have: %v", capacities)
+			return
+		}
+	}
+
+	pool := BytesPool{SizeClasses: capacities}
+	bytes := pool.Get(50)
+	if cap(bytes) < 50 || cap(bytes) > 63 {
+		t.Fatalf("capacity error, want: [50, 63], have: %d", cap(bytes))
+	}
+}
+
+func TestLargeSizeClasses(t *testing.T) {
+	pool := BytesPool{
+		LargeCapacityCeiling: 64 * 1024 * 1024,
+		LargeRetention:       1,
+	}
+
+	bytes1 := pool.Get(5 * 1024 * 1024)
+	if cap(bytes1) != 8*1024*1024 {
+		t.Fatalf("capacity error, want: %d, have: %d", 8*1024*1024, cap(bytes1))
+		return
+	}
+	bytes2 := pool.Get(5 * 1024 * 1024)
+	pool.Put(bytes1)
+	pool.Put(bytes2) // beyond the retention, dropped
+
+	bytes3 := pool.Get(6 * 1024 * 1024)
+	if &bytes3[:1][0] != &bytes1[:1][0] {
+		t.Fatal("large bytes should be reused")
+		return
+	}
+	_ = pool.Get(6 * 1024 * 1024)
+
+	class := pool.Stats().Classes[pool.getIndex(8*1024*1024)]
+	if class.Gets != 4 || class.News != 3 {
+		t.Fatalf("counter error, want: %d gets and %d news, have: %d gets and %d news", 4, 3, class.Gets, class.News)
+		return
+	}
+
+	bytes4 := pool.Get(64*1024*1024 + 1)
+	if cap(bytes4) != 64*1024*1024+1 {
+		t.Fatalf("oversize capacity error, want: %d, have: %d", 64*1024*1024+1, cap(bytes4))
+	}
+}
+
+func TestForeignBytes(t *testing.T) {
+	var pool BytesPool
+
+	// a class capacity, even the class is never used
+	if err := pool.TryPut(make([]byte, 0, 256)); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err := pool.TryPut(make([]byte, 0, 100)); err != ErrForeignBytes {
+		t.Fatalf("error missmatch, want: %v, have: %v", ErrForeignBytes, err)
+		return
+	}
+	if err := pool.TryPut(make([]byte, 0, largeCapacityUpper+1)); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// drop
+	pool.Put(make([]byte, 0, 100))
+
+	// rebucket
+	pool.ForeignPolicy = ForeignRebucket
+	pool.Put(make([]byte, 0, 100))
+	class := pool.Stats().Classes[pool.getIndex(64)]
+	if class.Puts != 1 {
+		t.Fatalf("puts error, want: %d, have: %d", 1, class.Puts)
+		return
+	}
+
+	// panic
+	pool.ForeignPolicy = ForeignPanic
+	defer func() {
+		if r := recover(); r != ErrForeignBytes {
+			t.Fatalf("panic missmatch, want: %v, have: %v", ErrForeignBytes, r)
+		}
+	}()
+	pool.Put(make([]byte, 0, 100))
+}
+
+func TestBytesPrewarm(t *testing.T) {
+	pool := BytesPool{SizedPoolFactory: BoundedPoolFactory(10, 0)}
+	pool.Prewarm(map[int]int{
+		100:                    3,
+		2000:                   1,
+		largeCapacityUpper + 1: 1,
+	})
+
+	for i := 0; i < 3; i++ {
+		pool.Get(100)
+	}
+	pool.Get(2000)
+
+	stats := pool.Stats()
+	if stats.Gets() != 4 || stats.HitRate() != 1 {
+		t.Fatalf("stats error, want: %d gets and hit rate %f, have: %d gets and hit rate %f", 4, 1.0, stats.Gets(), stats.HitRate())
+	}
+}
+
+func TestBytesPrewarmAligned(t *testing.T) {
+	pool := BytesPool{SizedPoolFactory: BoundedPoolFactory(10, 0), Alignment: 4096}
+	pool.Prewarm(map[int]int{100: 3})
+
+	for i := 0; i < 3; i++ {
+		bytes := pool.Get(100)
+		if arrayPointer(bytes)%4096 != 0 {
+			t.Fatal("bytes not aligned to 4096")
+			return
+		}
+	}
+	if rate := pool.Stats().HitRate(); rate != 1 {
+		t.Fatalf("the prewarmed bytes should be aligned, want: hit rate %f, have: hit rate %f", 1.0, rate)
+	}
+}
+
+func TestGetAligned(t *testing.T) {
+	var pool BytesPool
+	for _, alignment := range []int{512, 4096} {
+		for _, length := range []int{1, 100, 5000, largeCapacityUpper + 1} {
+			bytes := pool.GetAligned(length, alignment)
+			if len(bytes) != length {
+				t.Fatalf("length error, want: %d, have: %d", length, len(bytes))
+				return
+			}
+			if arrayPointer(bytes)%uintptr(alignment) != 0 {
+				t.Fatalf("bytes not aligned to %d, length: %d", alignment, length)
+				return
+			}
+			pool.Put(bytes)
+		}
+	}
+
+	// a misaligned bytes in the pool is not reused
+	pool = BytesPool{SizedPoolFactory: BoundedPoolFactory(10, 0)}
+	bytes := pool.allocate(256+1, 0)[1:1:257]
+	pool.Put(bytes)
+	if arrayPointer(pool.GetAligned(200, 2))%2 != 0 {
+		t.Fatal("bytes not aligned to 2")
+	}
+}
+
+func TestZeroOnPut(t *testing.T) {
+	for _, debug := range []DebugFlags{0, DebugPoison} {
+		pool := BytesPool{
+			SizedPoolFactory: BoundedPoolFactory(1, 0),
+			ZeroOnPut:        true,
+			Debug:            debug,
+		}
+
+		bytes := pool.Get(100)
+		for idx := range bytes {
+			bytes[idx] = 1
+		}
+		pool.Put(bytes)
+
+		bytes = pool.Get(128)
+		for idx, b := range bytes {
+			if b != 0 {
+				t.Fatalf("bytes should be zeroed, have: %d at %d, debug: %d", b, idx, debug)
+				return
+			}
+		}
+	}
+}
+
+func TestGetZeroed(t *testing.T) {
+	pool := BytesPool{SizedPoolFactory: BoundedPoolFactory(1, 0)}
+
+	bytes := pool.Get(100)
+	for idx := range bytes {
+		bytes[idx] = 1
+	}
+	pool.Put(bytes)
+
+	bytes = pool.GetZeroed(100)
+	for idx, b := range bytes {
+		if b != 0 {
+			t.Fatalf("bytes should be zeroed, have: %d at %d", b, idx)
+			return
+		}
+	}
+}
+
+func TestDefaultSizedBytesPoolFactory(t *testing.T) {
+	p := DefaultSizedBytesPoolFactory(128)
+	bytes, ok := p.Get().([]byte)
+	if !ok || cap(bytes) != 128 {
+		t.Fatalf("the produced pool should allocate the bytes, have: %v", p.Get())
+		return
+	}
+
+	pool := BytesPool{SizedPoolFactory: DefaultSizedBytesPoolFactory}
+	for i := 0; i < 100; i++ {
+		pool.Get(100)
+	}
+	// the bytes allocated by New are counted.
+	if stats := pool.Stats(); stats.Gets() != 100 || stats.HitRate() != 0 {
+		t.Fatalf("stats error, want: %d gets and hit rate %f, have: %d gets and hit rate %f", 100, 0.0, stats.Gets(), stats.HitRate())
+	}
+}
+
+func TestBytesStatsConcurrency(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	var pool BytesPool
+	pool.Put(pool.Get(100))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				pool.Put(pool.Get(100))
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the counters of all stripes are summed.
+	class := pool.Stats().Classes[pool.getIndex(100)]
+	if class.Gets != 8001 || class.Puts != 8001 {
+		t.Fatalf("counter error, want: %d gets and %d puts, have: %d gets and %d puts", 8001, 8001, class.Gets, class.Puts)
+	}
+}
@@ -0,0 +1,222 @@
+package bytespool
+
+import (
+	"io"
+	"net"
+)
+
+// DefaultChunkSize is the default value of ChunkedBuffer.ChunkSize.
+var DefaultChunkSize = 16 * 1024
+
+// ChunkedBuffer is a buffer of a chain of fixed size chunks from a bytes pool.
+// Unlike Buffer, it grows by appending chunks, without copying the written bytes,
+// and the read chunks are released at once.
+// It must be reset to release the chunks after use.
+type ChunkedBuffer struct {
+	// BytesPool is a pool of the chunks.
+	// It must not be changed while the buffer holds chunks.
+	// default is DefaultBytesPool.
+	BytesPool SizedBytesPool
+
+	// ChunkSize is the length of the chunks acquired from the pool,
+	// the capacity of the chunks may be larger.
+	// default is DefaultChunkSize.
+	ChunkSize int
+
+	// ReadBuffLength is the minimum free length of the last chunk in ReadFrom,
+	// a new chunk is appended if it has less.
+	// default is DefaultBufferReadBuffLength.
+	ReadBuffLength int
+
+	chunks [][]byte
+
+	// readOffset is the offset of the unread portion in the first chunk.
+	readOffset int
+}
+
+func (buffer *ChunkedBuffer) pool() SizedBytesPool {
+	if buffer.BytesPool == nil {
+		return DefaultBytesPool
+	}
+	return buffer.BytesPool
+}
+
+// Len returns the number of bytes of the unread portion of the buffer.
+func (buffer *ChunkedBuffer) Len() int {
+	var length int
+	for _, chunk := range buffer.chunks {
+		length += len(chunk)
+	}
+	return length - buffer.readOffset
+}
+
+// Buffers returns the unread portion of the buffer as net.Buffers, for vectored writes.
+// The chunks are valid only until the next modification of the buffer.
+func (buffer *ChunkedBuffer) Buffers() net.Buffers {
+	buffers := make(net.Buffers, 0, len(buffer.chunks))
+	for idx, chunk := range buffer.chunks {
+		if idx == 0 {
+			chunk = chunk[buffer.readOffset:]
+		}
+		if len(chunk) > 0 {
+			buffers = append(buffers, chunk)
+		}
+	}
+	return buffers
+}
+
+// tail returns the free portion of the last chunk, appending a new chunk if it has less than n bytes.
+func (buffer *ChunkedBuffer) tail(n int) []byte {
+	if len(buffer.chunks) > 0 {
+		last := buffer.chunks[len(buffer.chunks)-1]
+		if cap(last)-len(last) >= n {
+			return last[len(last):cap(last)]
+		}
+	}
+
+	if buffer.ChunkSize == 0 {
+		buffer.ChunkSize = DefaultChunkSize
+	}
+	chunk := buffer.pool().Get(buffer.ChunkSize)[:0]
+	buffer.chunks = append(buffer.chunks, chunk)
+	return chunk[:cap(chunk)]
+}
+
+// extend extends the last chunk by n bytes.
+func (buffer *ChunkedBuffer) extend(n int) {
+	last := len(buffer.chunks) - 1
+	buffer.chunks[last] = buffer.chunks[last][:len(buffer.chunks[last])+n]
+}
+
+// Write appends the contents of p to the buffer, appending chunks as needed.
+func (buffer *ChunkedBuffer) Write(p []byte) (int, error) {
+	var nWrote int
+	for len(p) > 0 {
+		n := copy(buffer.tail(1), p)
+		buffer.extend(n)
+		p = p[n:]
+		nWrote += n
+	}
+	return nWrote, nil
+}
+
+// WriteString appends the contents of str to the buffer, appending chunks as needed.
+func (buffer *ChunkedBuffer) WriteString(str string) (int, error) {
+	var nWrote int
+	for len(str) > 0 {
+		n := copy(buffer.tail(1), str)
+		buffer.extend(n)
+		str = str[n:]
+		nWrote += n
+	}
+	return nWrote, nil
+}
+
+// ReadFrom reads data from r until EOF and appends it to the buffer, appending chunks as needed.
+func (buffer *ChunkedBuffer) ReadFrom(r io.Reader) (int64, error) {
+	if buffer.ReadBuffLength == 0 {
+		buffer.ReadBuffLength = DefaultBufferReadBuffLength
+	}
+
+	var nRead int64
+	for {
+		n, err := r.Read(buffer.tail(buffer.ReadBuffLength))
+		buffer.extend(n)
+		nRead += int64(n)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return nRead, err
+		}
+	}
+}
+
+// consume advances the buffer by n read bytes, and releases the drained chunks.
+// The last chunk is kept for writing if it is not full.
+func (buffer *ChunkedBuffer) consume(n int) {
+	for len(buffer.chunks) > 0 {
+		chunk := buffer.chunks[0]
+		unread := len(chunk) - buffer.readOffset
+		if n < unread {
+			buffer.readOffset += n
+			return
+		}
+		n -= unread
+
+		if len(buffer.chunks) == 1 && len(chunk) < cap(chunk) {
+			buffer.chunks[0] = chunk[:0]
+			buffer.readOffset = 0
+			return
+		}
+		buffer.pool().Put(chunk)
+		buffer.chunks[0] = nil
+		buffer.chunks = buffer.chunks[1:]
+		buffer.readOffset = 0
+	}
+}
+
+// Read reads the next len(p) bytes from the buffer or until the buffer is drained.
+// If the buffer has no data to return, return 0, io.EOF, unless len(p) is zero.
+func (buffer *ChunkedBuffer) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if buffer.Len() == 0 {
+		return 0, io.EOF
+	}
+
+	var nRead int
+	for _, chunk := range buffer.Buffers() {
+		n := copy(p[nRead:], chunk)
+		nRead += n
+		if nRead == len(p) {
+			break
+		}
+	}
+	buffer.consume(nRead)
+	return nRead, nil
+}
+
+// WriteTo writes data to w until the buffer is drained or an error occurs.
+// The chunks are written by a vectored write if w is a net.Conn.
+// It retries the partial writes, and returns io.ErrShortWrite if a write makes no progress without an error.
+func (buffer *ChunkedBuffer) WriteTo(w io.Writer) (int64, error) {
+	if _, ok := w.(net.Conn); ok {
+		buffers := buffer.Buffers()
+		nWrote, err := buffers.WriteTo(w)
+		buffer.consume(int(nWrote))
+		return nWrote, err
+	}
+
+	var nWrote int64
+	for _, chunk := range buffer.Buffers() {
+		for len(chunk) > 0 {
+			n, err := w.Write(chunk)
+			if n < 0 || n > len(chunk) {
+				panic("bytespool.ChunkedBuffer.WriteTo: invalid Write count")
+			}
+			buffer.consume(n)
+			nWrote += int64(n)
+			chunk = chunk[n:]
+			if err != nil {
+				return nWrote, err
+			}
+			if n == 0 {
+				return nWrote, io.ErrShortWrite
+			}
+		}
+	}
+	return nWrote, nil
+}
+
+// Reset releases the chunks, and empties the buffer.
+func (buffer *ChunkedBuffer) Reset() {
+	pool := buffer.pool()
+	for idx, chunk := range buffer.chunks {
+		pool.Put(chunk)
+		buffer.chunks[idx] = nil
+	}
+	buffer.chunks = buffer.chunks[:0]
+	buffer.readOffset = 0
+}
@@ -0,0 +1,143 @@
+package bytespool
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestChunkedBuffer(t *testing.T) {
+	pool := &BytesPool{}
+	buffer := ChunkedBuffer{BytesPool: pool, ChunkSize: 1024}
+	defer buffer.Reset()
+
+	rand.Seed(time.Now().Unix())
+	data := make([]byte, 100*1024+rand.Intn(1024))
+	rand.Read(data)
+
+	// write
+	for p := data; len(p) > 0; {
+		n := rand.Intn(3000) + 1
+		if n > len(p) {
+			n = len(p)
+		}
+		buffer.Write(p[:n])
+		p = p[n:]
+	}
+	if buffer.Len() != len(data) {
+		t.Fatalf("length error, want: %d, have: %d", len(data), buffer.Len())
+		return
+	}
+	if !bytes.Equal(bytes.Join(buffer.Buffers(), nil), data) {
+		t.Fatal("buffers data missmatch")
+		return
+	}
+
+	// read
+	var have []byte
+	for {
+		buff := make([]byte, rand.Intn(3000)+1)
+		n, err := buffer.Read(buff)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		have = append(have, buff[:n]...)
+	}
+	if !bytes.Equal(have, data) {
+		t.Fatal("read data missmatch")
+		return
+	}
+
+	// the drained chunks are released
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding > 1024 {
+		t.Fatalf("outstanding bytes error, want: <= %d, have: %d", 1024, outstanding)
+	}
+}
+
+func TestChunkedBufferReadFromWriteTo(t *testing.T) {
+	pool := &BytesPool{}
+	buffer := ChunkedBuffer{BytesPool: pool, ChunkSize: 4096}
+
+	data := make([]byte, 1024*1024+rand.Intn(1024))
+	rand.Read(data)
+
+	nRead, err := buffer.ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if int(nRead) != len(data) || buffer.Len() != len(data) {
+		t.Fatalf("read length missmatch, want: %d, have: %d, %d", len(data), nRead, buffer.Len())
+		return
+	}
+
+	writer := &throttledWriter{limit: 1000, fail: -1}
+	nWrote, err := buffer.WriteTo(writer)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if int(nWrote) != len(data) || !bytes.Equal(writer.Bytes(), data) {
+		t.Fatalf("write data missmatch, length: %d", nWrote)
+		return
+	}
+
+	buffer.Reset()
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("outstanding bytes error, want: %d, have: %d", 0, outstanding)
+	}
+}
+
+func TestChunkedBufferWriteToConn(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+		return
+	}
+	defer listener.Close()
+
+	data := make([]byte, 256*1024)
+	rand.Read(data)
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		have, _ := io.ReadAll(conn)
+		received <- have
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	var buffer ChunkedBuffer
+	defer buffer.Reset()
+	buffer.Write(data)
+	nWrote, err := buffer.WriteTo(conn)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if int(nWrote) != len(data) || buffer.Len() != 0 {
+		t.Fatalf("write length error, want: %d, have: %d, unread: %d", len(data), nWrote, buffer.Len())
+		return
+	}
+	if !bytes.Equal(<-received, data) {
+		t.Fatal("received data missmatch")
+	}
+}
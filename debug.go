@@ -0,0 +1,177 @@
+package bytespool
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// PoisonByte is the byte that fills the released bytes in DebugPoison mode.
+const PoisonByte = 0xDB
+
+// DebugFlags enables the debug checks of BytesPool.
+// The checks are expensive, they are intended for tests and debug builds.
+type DebugFlags int
+
+const (
+	// DebugPoison fills the released bytes with PoisonByte,
+	// and verifies the bytes at the next acquirement.
+	// If the bytes were written after the release, it panics with *UseAfterPutError.
+	DebugPoison DebugFlags = 1 << iota
+
+	// DebugTrackPuts records the acquired bytes by the backing array.
+	// Put panics, and TryPut returns an error,
+	// if the bytes was already released, or was never acquired from the pool.
+	// A false report is possible if the address of the bytes dropped by the pool is reused.
+	DebugTrackPuts
+)
+
+var (
+	// ErrDoublePut means the bytes was already released.
+	ErrDoublePut = errors.New("bytespool: bytes put twice")
+
+	// ErrNotFromPool means the bytes was never acquired from the pool.
+	ErrNotFromPool = errors.New("bytespool: bytes not acquired from the pool")
+)
+
+// UseAfterPutError represents the bytes were written after it was released.
+type UseAfterPutError struct {
+	// Capacity is the capacity of the bytes.
+	Capacity int
+
+	// Offset is the offset of the first written byte.
+	Offset int
+
+	// PutStack is the stack trace of the release.
+	PutStack string
+}
+
+func (err *UseAfterPutError) Error() string {
+	return fmt.Sprintf("bytespool: bytes written after put, capacity: %d, offset: %d, put at:\n%s", err.Capacity, err.Offset, err.PutStack)
+}
+
+// arrayPointer returns the address of the backing array of the bytes.
+func arrayPointer(bytes []byte) uintptr {
+	if cap(bytes) == 0 {
+		return 0
+	}
+	return uintptr(unsafe.Pointer(&bytes[:1][0]))
+}
+
+// callers returns the program counters of the caller's stack,
+// skip is the number of stack frames to skip, 0 identifying the caller of callers.
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip+2, pcs)
+	return pcs[:n]
+}
+
+// formatStack formats the program counters like a stack trace of panic.
+func formatStack(pcs []uintptr) string {
+	var builder strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&builder, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return builder.String()
+}
+
+// debugRecord is the debug information of the bytes.
+type debugRecord struct {
+	outstanding bool
+	putStack    []uintptr
+}
+
+// debugger records the debug information of the bytes by the backing array.
+// The records are keyed by address, so they do not keep the bytes alive.
+type debugger struct {
+	mutex   sync.Mutex
+	records map[uintptr]*debugRecord
+}
+
+// get verifies and records the acquired bytes.
+// fresh represents the bytes is newly allocated.
+func (d *debugger) get(bytes []byte, flags DebugFlags, fresh bool) {
+	bytes = bytes[:cap(bytes)]
+	ptr := arrayPointer(bytes)
+
+	d.mutex.Lock()
+	record := d.records[ptr]
+	var putStack []uintptr
+	if record != nil && !fresh {
+		putStack = record.putStack
+	}
+	if flags&DebugTrackPuts != 0 {
+		if record == nil {
+			if d.records == nil {
+				d.records = make(map[uintptr]*debugRecord)
+			}
+			record = &debugRecord{}
+			d.records[ptr] = record
+		}
+		record.outstanding = true
+		record.putStack = nil
+	} else if record != nil {
+		delete(d.records, ptr)
+	}
+	d.mutex.Unlock()
+
+	if flags&DebugPoison == 0 || putStack == nil {
+		// not poisoned by this pool
+		return
+	}
+	for idx, b := range bytes {
+		if b != PoisonByte {
+			panic(&UseAfterPutError{
+				Capacity: cap(bytes),
+				Offset:   idx,
+				PutStack: formatStack(putStack),
+			})
+		}
+	}
+}
+
+// put verifies and records the released bytes.
+// foreign represents the bytes is adopted by the pool, it was never acquired.
+func (d *debugger) put(bytes []byte, flags DebugFlags, foreign bool) error {
+	bytes = bytes[:cap(bytes)]
+	ptr := arrayPointer(bytes)
+	pcs := callers(1)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	record := d.records[ptr]
+	if flags&DebugTrackPuts != 0 && !foreign {
+		if record == nil {
+			return ErrNotFromPool
+		}
+		if !record.outstanding {
+			return fmt.Errorf("%w, previous put at:\n%s", ErrDoublePut, formatStack(record.putStack))
+		}
+	}
+
+	if flags&DebugPoison != 0 {
+		for idx := range bytes {
+			bytes[idx] = PoisonByte
+		}
+	}
+
+	if record == nil {
+		if d.records == nil {
+			d.records = make(map[uintptr]*debugRecord)
+		}
+		record = &debugRecord{}
+		d.records[ptr] = record
+	}
+	record.outstanding = false
+	record.putStack = pcs
+	return nil
+}
@@ -0,0 +1,69 @@
+package bytespool
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDebugPoison(t *testing.T) {
+	pool := BytesPool{
+		Debug:            DebugPoison,
+		SizedPoolFactory: func(size int) Pool { return NewBoundedPool(1) },
+	}
+
+	// a clean put and get
+	bytes := pool.Get(100)
+	pool.Put(bytes)
+	bytes = pool.Get(100)
+
+	// write after put
+	pool.Put(bytes)
+	bytes[10] = 1
+
+	defer func() {
+		err, ok := recover().(*UseAfterPutError)
+		if !ok {
+			t.Fatalf("panic missmatch, want: *UseAfterPutError, have: %v", err)
+			return
+		}
+		if err.Offset != 10 {
+			t.Fatalf("offset error, want: %d, have: %d", 10, err.Offset)
+			return
+		}
+		if !strings.Contains(err.PutStack, "TestDebugPoison") {
+			t.Fatalf("put stack should contain the test, have: %s", err.PutStack)
+		}
+	}()
+	pool.Get(100)
+}
+
+func TestDebugTrackPuts(t *testing.T) {
+	pool := BytesPool{Debug: DebugTrackPuts | DebugPoison}
+
+	bytes := pool.Get(100)
+	if err := pool.TryPut(bytes); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err := pool.TryPut(bytes); !errors.Is(err, ErrDoublePut) {
+		t.Fatalf("error missmatch, want: %v, have: %v", ErrDoublePut, err)
+		return
+	}
+	if err := pool.TryPut(make([]byte, 0, 128)); err != ErrNotFromPool {
+		t.Fatalf("error missmatch, want: %v, have: %v", ErrNotFromPool, err)
+		return
+	}
+
+	// acquired again, released again
+	bytes = pool.Get(100)
+	pool.Put(bytes)
+
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.Is(err, ErrDoublePut) {
+			t.Fatalf("panic missmatch, want: %v, have: %v", ErrDoublePut, err)
+		}
+	}()
+	pool.Put(bytes)
+}
@@ -0,0 +1,128 @@
+package bytespool
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Allocation represents a outstanding bytes or buffer.
+type Allocation struct {
+	// Kind is "bytes" or "buffer".
+	Kind string
+
+	// Capacity is the capacity of the bytes, 0 for a buffer.
+	Capacity int
+
+	// Time is the time of the acquirement.
+	Time time.Time
+
+	// Stack is the stack trace of the acquirement.
+	Stack string
+}
+
+func (allocation Allocation) String() string {
+	return fmt.Sprintf("%s, capacity: %d, acquired at %s:\n%s", allocation.Kind, allocation.Capacity, allocation.Time.Format(time.RFC3339Nano), allocation.Stack)
+}
+
+// leakRecord is the record of a outstanding bytes or buffer.
+type leakRecord struct {
+	kind     string
+	capacity int
+	time     time.Time
+	stack    []uintptr
+}
+
+// LeakTracker records the stack trace of each acquirement,
+// and lists the bytes and buffers that are still outstanding.
+// It is also a http.Handler that writes the outstanding allocations as plain text.
+// The records are keyed by address, so they do not keep the bytes and buffers alive.
+// The zero value is ready to use.
+type LeakTracker struct {
+	mutex   sync.Mutex
+	records map[uintptr]leakRecord
+}
+
+func (tracker *LeakTracker) track(ptr uintptr, kind string, capacity int) {
+	record := leakRecord{
+		kind:     kind,
+		capacity: capacity,
+		time:     time.Now(),
+		stack:    callers(1),
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	if tracker.records == nil {
+		tracker.records = make(map[uintptr]leakRecord)
+	}
+	tracker.records[ptr] = record
+}
+
+func (tracker *LeakTracker) untrack(ptr uintptr) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	delete(tracker.records, ptr)
+}
+
+// Len returns the number of the outstanding allocations.
+func (tracker *LeakTracker) Len() int {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	return len(tracker.records)
+}
+
+// Outstanding returns the outstanding allocations, the oldest first.
+func (tracker *LeakTracker) Outstanding() []Allocation {
+	tracker.mutex.Lock()
+	records := make([]leakRecord, 0, len(tracker.records))
+	for _, record := range tracker.records {
+		records = append(records, record)
+	}
+	tracker.mutex.Unlock()
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].time.Before(records[j].time)
+	})
+
+	allocations := make([]Allocation, 0, len(records))
+	for _, record := range records {
+		allocations = append(allocations, Allocation{
+			Kind:     record.kind,
+			Capacity: record.capacity,
+			Time:     record.time,
+			Stack:    formatStack(record.stack),
+		})
+	}
+	return allocations
+}
+
+// Reset forgets all the outstanding allocations.
+func (tracker *LeakTracker) Reset() {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	tracker.records = nil
+}
+
+// ServeHTTP writes the outstanding allocations as plain text.
+func (tracker *LeakTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	allocations := tracker.Outstanding()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%d outstanding allocations\n", len(allocations))
+	for _, allocation := range allocations {
+		fmt.Fprintf(w, "\n%s", allocation)
+	}
+}
+
+// bufferPointer returns the address of the buffer.
+func bufferPointer(buffer *Buffer) uintptr {
+	return uintptr(unsafe.Pointer(buffer))
+}
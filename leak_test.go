@@ -0,0 +1,67 @@
+package bytespool
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLeakTracker(t *testing.T) {
+	var tracker LeakTracker
+	pool := BytesPool{LeakTracker: &tracker}
+
+	bytes1 := pool.Get(100)
+	bytes2 := pool.Get(largeCapacityUpper + 1)
+	_ = pool.Get(0)
+	pool.Put(bytes2)
+
+	allocations := tracker.Outstanding()
+	if len(allocations) != 1 {
+		t.Fatalf("outstanding length error, want: %d, have: %d", 1, len(allocations))
+		return
+	}
+	if allocations[0].Kind != "bytes" || allocations[0].Capacity != 128 {
+		t.Fatalf("allocation error, have: %s", allocations[0])
+		return
+	}
+	if !strings.Contains(allocations[0].Stack, "TestLeakTracker") {
+		t.Fatalf("stack should contain the test, have: %s", allocations[0].Stack)
+		return
+	}
+
+	// http handler
+	recorder := httptest.NewRecorder()
+	tracker.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+	if !strings.HasPrefix(recorder.Body.String(), "1 outstanding allocations") {
+		t.Fatalf("http body error, have: %s", recorder.Body.String())
+		return
+	}
+
+	pool.Put(bytes1)
+	if tracker.Len() != 0 {
+		t.Fatalf("outstanding length error, want: %d, have: %d", 0, tracker.Len())
+	}
+}
+
+func TestBufferLeakTracker(t *testing.T) {
+	var tracker LeakTracker
+	BufferLeakTracker = &tracker
+	defer func() {
+		BufferLeakTracker = nil
+	}()
+
+	buffer := GetBuffer()
+	if tracker.Len() != 1 {
+		t.Fatalf("outstanding length error, want: %d, have: %d", 1, tracker.Len())
+		return
+	}
+	if kind := tracker.Outstanding()[0].Kind; kind != "buffer" {
+		t.Fatalf("kind error, want: %s, have: %s", "buffer", kind)
+		return
+	}
+
+	PutBuffer(buffer)
+	if tracker.Len() != 0 {
+		t.Fatalf("outstanding length error, want: %d, have: %d", 0, tracker.Len())
+	}
+}
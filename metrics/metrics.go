@@ -0,0 +1,187 @@
+// Package metrics exports the counters of the pools of bytespool,
+// in the Prometheus text exposition format and through expvar.
+// It depends on the standard library only.
+package metrics
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/wencan/bytespool"
+)
+
+// DefaultNamespace is the default value of Exporter.Namespace.
+var DefaultNamespace = "bytespool"
+
+// Exporter exports the counters of a BytesPool and a BufferPool.
+// The zero value exports DefaultBytesPool and BufferPool of bytespool.
+// The size classes never acquired from and without idle bytes are omitted,
+// as most of the size classes are unused.
+type Exporter struct {
+	// Namespace is the prefix of the metric names.
+	// default is DefaultNamespace.
+	Namespace string
+
+	// BytesPool is the exported bytes pool.
+	// default is bytespool.DefaultBytesPool.
+	BytesPool *bytespool.BytesPool
+
+	// BufferPool is the exported buffer pool.
+	// default is bytespool.BufferPool.
+	BufferPool *bytespool.TypedPool[*bytespool.Buffer]
+}
+
+func (exporter *Exporter) namespace() string {
+	if exporter.Namespace == "" {
+		return DefaultNamespace
+	}
+	return exporter.Namespace
+}
+
+func (exporter *Exporter) bytesPool() *bytespool.BytesPool {
+	if exporter.BytesPool == nil {
+		return bytespool.DefaultBytesPool
+	}
+	return exporter.BytesPool
+}
+
+func (exporter *Exporter) bufferPool() *bytespool.TypedPool[*bytespool.Buffer] {
+	if exporter.BufferPool == nil {
+		return bytespool.BufferPool
+	}
+	return exporter.BufferPool
+}
+
+// metric is a family of samples in the Prometheus text exposition format.
+type metric struct {
+	name    string
+	kind    string
+	help    string
+	samples []sample
+}
+
+// sample is a sample of a metric, labeled by the capacity of a size class if it is not 0.
+type sample struct {
+	capacity int
+	value    float64
+}
+
+// metrics returns a snapshot of the metrics of the pools.
+func (exporter *Exporter) metrics() []metric {
+	namespace := exporter.namespace()
+	stats := exporter.bytesPool().Stats()
+	bufferStats := exporter.bufferPool().Stats()
+
+	hits := metric{name: namespace + "_class_hits_total", kind: "counter", help: "Number of the bytes reused by the size class."}
+	misses := metric{name: namespace + "_class_misses_total", kind: "counter", help: "Number of the bytes allocated because the size class was empty."}
+	idle := metric{name: namespace + "_class_idle_bytes", kind: "gauge", help: "Approximate capacity of the idle bytes of the size class."}
+	outstanding := metric{name: namespace + "_class_outstanding_bytes", kind: "gauge", help: "Capacity of the bytes acquired from the size class and not yet released."}
+	for _, class := range stats.Classes {
+		if class.Gets == 0 && class.Idle == 0 {
+			continue
+		}
+		hits.samples = append(hits.samples, sample{class.Capacity, float64(class.Hits())})
+		misses.samples = append(misses.samples, sample{class.Capacity, float64(class.News)})
+		idle.samples = append(idle.samples, sample{class.Capacity, float64(class.IdleBytes())})
+		outstanding.samples = append(outstanding.samples, sample{class.Capacity, float64(class.OutstandingBytes())})
+	}
+
+	return []metric{
+		hits,
+		misses,
+		idle,
+		outstanding,
+		{
+			name:    namespace + "_oversize_allocations_total",
+			kind:    "counter",
+			help:    "Number of the bytes allocated without pooling because the length exceeds the largest size class.",
+			samples: []sample{{value: float64(stats.OversizeGets)}},
+		},
+		{
+			name:    namespace + "_buffer_hits_total",
+			kind:    "counter",
+			help:    "Number of the buffers reused by the buffer pool.",
+			samples: []sample{{value: float64(bufferStats.Hits())}},
+		},
+		{
+			name:    namespace + "_buffer_misses_total",
+			kind:    "counter",
+			help:    "Number of the buffers created because the buffer pool was empty.",
+			samples: []sample{{value: float64(bufferStats.News)}},
+		},
+		{
+			name:    namespace + "_buffer_outstanding",
+			kind:    "gauge",
+			help:    "Number of the buffers acquired from the buffer pool and not yet released.",
+			samples: []sample{{value: float64(bufferStats.Outstanding())}},
+		},
+	}
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (exporter *Exporter) WriteTo(w io.Writer) (int64, error) {
+	writer := countWriter{w: w}
+	buffered := bufio.NewWriter(&writer)
+	for _, metric := range exporter.metrics() {
+		fmt.Fprintf(buffered, "# HELP %s %s\n", metric.name, metric.help)
+		fmt.Fprintf(buffered, "# TYPE %s %s\n", metric.name, metric.kind)
+		for _, sample := range metric.samples {
+			value := strconv.FormatFloat(sample.value, 'g', -1, 64)
+			if sample.capacity != 0 {
+				fmt.Fprintf(buffered, "%s{capacity=\"%d\"} %s\n", metric.name, sample.capacity, value)
+			} else {
+				fmt.Fprintf(buffered, "%s %s\n", metric.name, value)
+			}
+		}
+	}
+	err := buffered.Flush()
+	return writer.n, err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (exporter *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	exporter.WriteTo(w)
+}
+
+// Var returns a expvar.Var of the metrics, which is marshaled to a JSON object
+// mapping the metric names to the values, or to the objects mapping the capacities to the values.
+func (exporter *Exporter) Var() expvar.Var {
+	return expvar.Func(func() interface{} {
+		values := make(map[string]interface{})
+		for _, metric := range exporter.metrics() {
+			if len(metric.samples) == 1 && metric.samples[0].capacity == 0 {
+				values[metric.name] = metric.samples[0].value
+				continue
+			}
+			classes := make(map[string]float64, len(metric.samples))
+			for _, sample := range metric.samples {
+				classes[strconv.Itoa(sample.capacity)] = sample.value
+			}
+			values[metric.name] = classes
+		}
+		return values
+	})
+}
+
+// Publish publishes the metrics to expvar with the name.
+// Like expvar.Publish, it panics if the name is already registered.
+func (exporter *Exporter) Publish(name string) {
+	expvar.Publish(name, exporter.Var())
+}
+
+// countWriter counts the bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (writer *countWriter) Write(p []byte) (int, error) {
+	n, err := writer.w.Write(p)
+	writer.n += int64(n)
+	return n, err
+}
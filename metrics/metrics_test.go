@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wencan/bytespool"
+)
+
+func newExporter() *Exporter {
+	pool := &bytespool.BytesPool{
+		SizedPoolFactory: bytespool.BoundedPoolFactory(1, 0),
+		SizeClasses:      []int{64, 128},
+	}
+	bytes := pool.Get(100)
+	pool.Put(bytes)
+	pool.Get(100)
+	pool.Get(1000)
+
+	bufferPool := &bytespool.TypedPool[*bytespool.Buffer]{
+		Pool: bytespool.NewBoundedPool(1),
+		New: func() *bytespool.Buffer {
+			return new(bytespool.Buffer)
+		},
+	}
+	bufferPool.Get()
+
+	return &Exporter{
+		Namespace:  "test",
+		BytesPool:  pool,
+		BufferPool: bufferPool,
+	}
+}
+
+func TestExporterServeHTTP(t *testing.T) {
+	exporter := newExporter()
+
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain") {
+		t.Fatalf("content type error, have: %s", contentType)
+		return
+	}
+
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE test_class_hits_total counter",
+		`test_class_hits_total{capacity="128"} 1`,
+		`test_class_misses_total{capacity="128"} 1`,
+		`test_class_idle_bytes{capacity="128"} 0`,
+		`test_class_outstanding_bytes{capacity="128"} 128`,
+		"test_oversize_allocations_total 1",
+		"test_buffer_misses_total 1",
+		"test_buffer_outstanding 1",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("missing line: %s, have:\n%s", line, body)
+			return
+		}
+	}
+	if strings.Contains(body, `capacity="64"`) {
+		t.Fatalf("the unused size class should be omitted, have:\n%s", body)
+	}
+}
+
+func TestExporterVar(t *testing.T) {
+	exporter := newExporter()
+
+	var values struct {
+		Hits      map[string]float64 `json:"test_class_hits_total"`
+		Oversizes float64            `json:"test_oversize_allocations_total"`
+	}
+	err := json.Unmarshal([]byte(exporter.Var().String()), &values)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if values.Hits["128"] != 1 {
+		t.Fatalf("hits error, want: %d, have: %v", 1, values.Hits["128"])
+	}
+	if _, ok := values.Hits["64"]; ok {
+		t.Fatal("the unused size class should be omitted")
+	}
+	if values.Oversizes != 1 {
+		t.Fatalf("oversizes error, want: %d, have: %v", 1, values.Oversizes)
+	}
+}
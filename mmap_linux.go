@@ -0,0 +1,173 @@
+//go:build linux
+
+package bytespool
+
+import (
+	"os"
+	"sync"
+	"syscall"
+)
+
+// DefaultMmapRegionSize is the default value of MmapArena.RegionSize.
+var DefaultMmapRegionSize = 64 * 1024 * 1024
+
+// MmapArena carves the bytes from anonymous mmap regions,
+// which are invisible to the garbage collector.
+// Its NewPool is a SizedBytesPoolFactory, the produced pools return ordinary bytes.
+// The pools are BytesAllocators, their Get returns nil if the pool is empty,
+// then BytesPool carves the new bytes from the arena, and counts them like the other pools,
+// so Stats and Trim work with them.
+// The regions are never unmapped, as the bytes may still be referenced,
+// but the pages of the discarded bytes are released by madvise(MADV_DONTNEED).
+// The bytes must not hold Go pointers, which is true for []byte.
+type MmapArena struct {
+	// RegionSize is the minimum size of a mmap region.
+	// default is DefaultMmapRegionSize.
+	RegionSize int
+
+	// HugePages advises the kernel to back the regions with transparent huge pages.
+	HugePages bool
+
+	mutex  sync.Mutex
+	region []byte
+	mapped int64
+}
+
+// MappedBytes returns the size of the mapped regions.
+func (arena *MmapArena) MappedBytes() int64 {
+	arena.mutex.Lock()
+	defer arena.mutex.Unlock()
+
+	return arena.mapped
+}
+
+// carve returns a bytes of the capacity from the current region,
+// or from a new region if the current one has no room.
+// The bytes is aligned to alignment if it is greater than 1.
+func (arena *MmapArena) carve(capacity int, alignment int) []byte {
+	arena.mutex.Lock()
+	defer arena.mutex.Unlock()
+
+	var offset int
+	if alignment > 1 && len(arena.region) > 0 {
+		offset = int(uintptr(alignment)-arrayPointer(arena.region)%uintptr(alignment)) % alignment
+	}
+	if len(arena.region) < offset+capacity {
+		size := arena.RegionSize
+		if size == 0 {
+			size = DefaultMmapRegionSize
+		}
+		// the regions are aligned to pages, the larger alignments need the room.
+		pageSize := os.Getpagesize()
+		room := capacity
+		if alignment > pageSize {
+			room += alignment - 1
+		}
+		if size < room {
+			size = room
+		}
+		size = (size + pageSize - 1) / pageSize * pageSize
+
+		region, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+		if err != nil {
+			panic(os.NewSyscallError("mmap", err))
+		}
+		if arena.HugePages {
+			// only a advice, the kernel may not support it.
+			_ = syscall.Madvise(region, syscall.MADV_HUGEPAGE)
+		}
+		arena.region = region
+		arena.mapped += int64(size)
+
+		offset = 0
+		if alignment > 1 {
+			offset = int(uintptr(alignment)-arrayPointer(region)%uintptr(alignment)) % alignment
+		}
+	}
+
+	bytes := arena.region[offset : offset : offset+capacity]
+	arena.region = arena.region[offset+capacity:]
+	return bytes
+}
+
+// NewPool creates a pool of the bytes of size, carved from the arena.
+// The pool keeps all idle bytes, as the garbage collector can not reclaim them.
+// It is a SizedBytesPoolFactory.
+func (arena *MmapArena) NewPool(size int) Pool {
+	return &mmapPool{
+		arena: arena,
+		size:  size,
+	}
+}
+
+// mmapPool is a pool of the bytes carved from a MmapArena.
+type mmapPool struct {
+	arena *MmapArena
+	size  int
+
+	mutex sync.Mutex
+	// idle is the resident idle bytes.
+	idle [][]byte
+	// released is the idle bytes that pages were released.
+	released [][]byte
+}
+
+// Get returns a idle bytes, or nil if the pool is empty.
+// The released bytes are reused before the new ones are carved.
+func (pool *mmapPool) Get() interface{} {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	if bytes, ok := pop(&pool.idle); ok {
+		return bytes
+	}
+	if bytes, ok := pop(&pool.released); ok {
+		return bytes
+	}
+	return nil
+}
+
+// AllocateBytes carves a new bytes from the arena.
+func (pool *mmapPool) AllocateBytes(alignment int) []byte {
+	return pool.arena.carve(pool.size, alignment)
+}
+
+// Put keeps the bytes.
+func (pool *mmapPool) Put(x interface{}) {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.idle = append(pool.idle, x.([]byte))
+}
+
+// Discard releases the pages of the bytes, and keeps the bytes for reuse.
+func (pool *mmapPool) Discard(x interface{}) {
+	bytes := x.([]byte)
+	bytes = bytes[:cap(bytes)]
+
+	// only the whole pages in the bytes
+	pageSize := uintptr(os.Getpagesize())
+	ptr := arrayPointer(bytes)
+	start := int((pageSize - ptr%pageSize) % pageSize)
+	end := start + (len(bytes)-start)/int(pageSize)*int(pageSize)
+	if start < end {
+		_ = syscall.Madvise(bytes[start:end], syscall.MADV_DONTNEED)
+	}
+
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+
+	pool.released = append(pool.released, bytes[:0])
+}
+
+// pop removes and returns the last bytes of the list.
+func pop(list *[][]byte) ([]byte, bool) {
+	n := len(*list)
+	if n == 0 {
+		return nil, false
+	}
+	bytes := (*list)[n-1]
+	(*list)[n-1] = nil
+	*list = (*list)[:n-1]
+	return bytes, true
+}
@@ -0,0 +1,73 @@
+//go:build linux
+
+package bytespool
+
+import "testing"
+
+func TestMmapArena(t *testing.T) {
+	arena := MmapArena{RegionSize: 1024 * 1024, HugePages: true}
+	pool := BytesPool{SizedPoolFactory: arena.NewPool}
+
+	bytes1 := pool.Get(100 * 1024)
+	for idx := range bytes1 {
+		bytes1[idx] = byte(idx)
+	}
+	bytes2 := pool.Get(100 * 1024)
+	if arena.MappedBytes() != 1024*1024 {
+		t.Fatalf("mapped bytes error, want: %d, have: %d", 1024*1024, arena.MappedBytes())
+		return
+	}
+
+	pool.Put(bytes1)
+	bytes3 := pool.Get(100 * 1024)
+	if &bytes3[0] != &bytes1[0] {
+		t.Fatal("mmap bytes should be reused")
+		return
+	}
+
+	// trim releases the pages, the bytes are still usable
+	pool.Put(bytes2)
+	pool.Put(bytes3)
+	if released := pool.Trim(0); released != 2*100*1024 {
+		t.Fatalf("released bytes error, want: %d, have: %d", 2*100*1024, released)
+		return
+	}
+	bytes4 := pool.Get(100 * 1024)
+	bytes4[len(bytes4)-1] = 1
+
+	// a new region
+	_ = pool.Get(1000 * 1024)
+	if arena.MappedBytes() != 2*1024*1024 {
+		t.Fatalf("mapped bytes error, want: %d, have: %d", 2*1024*1024, arena.MappedBytes())
+	}
+}
+
+func TestMmapArenaStats(t *testing.T) {
+	arena := MmapArena{RegionSize: 1024 * 1024}
+	pool := BytesPool{SizedPoolFactory: arena.NewPool, Alignment: 512}
+
+	// the prewarmed bytes are carved from the arena too.
+	pool.Prewarm(map[int]int{100 * 1024: 1})
+	if arena.MappedBytes() != 1024*1024 {
+		t.Fatalf("mapped bytes error, want: %d, have: %d", 1024*1024, arena.MappedBytes())
+		return
+	}
+
+	bytes1 := pool.Get(100 * 1024)
+	bytes2 := pool.Get(100 * 1024)
+	if arrayPointer(bytes1)%512 != 0 || arrayPointer(bytes2)%512 != 0 {
+		t.Fatal("bytes not aligned to 512")
+		return
+	}
+	stats := pool.Stats()
+	if stats.Gets() != 2 || stats.HitRate() != 0.5 {
+		t.Fatalf("stats error, want: %d gets and hit rate %f, have: %d gets and hit rate %f", 2, 0.5, stats.Gets(), stats.HitRate())
+		return
+	}
+
+	pool.Put(bytes1)
+	pool.Put(bytes2)
+	if released := pool.Trim(0); released != 2*100*1024 {
+		t.Fatalf("released bytes error, want: %d, have: %d", 2*100*1024, released)
+	}
+}
@@ -1,7 +1,112 @@
 package bytespool
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 // Pool is the interface of the universal pool.
 type Pool interface {
 	Get() interface{}
 	Put(x interface{})
 }
+
+// Discarder is implemented by the pools that own memory the garbage collector can not reclaim.
+// BytesPool calls Discard with the idle item it drops, like trimmed or beyond MaxIdleBytes,
+// instead of leaving it to the garbage collector.
+type Discarder interface {
+	Discard(x interface{})
+}
+
+// BytesAllocator is implemented by the pools of BytesPool that own the memory of their bytes, like the pools of MmapArena.
+// Their Get returns nil if the pool is empty like the other pools,
+// then BytesPool allocates the new bytes by AllocateBytes instead of on the Go heap.
+type BytesAllocator interface {
+	// AllocateBytes returns a new empty bytes of the size of the pool,
+	// aligned to alignment if it is greater than 1.
+	AllocateBytes(alignment int) []byte
+}
+
+// TypedPool is a typed pool built on Pool.
+type TypedPool[T any] struct {
+	// Pool is the pool of the objects.
+	// Its Get may return nil if the pool is empty.
+	// It takes effect at the first use of the typed pool.
+	// default is a sync.Pool.
+	Pool Pool
+
+	// New creates a object if the pool is empty.
+	// default is nil, Get returns the zero value of T if the pool is empty.
+	New func() T
+
+	// Reset resets a object before it is released.
+	// default is nil, the object is released as it is.
+	Reset func(T)
+
+	initOnce sync.Once
+	gets     atomic.Uint64
+	puts     atomic.Uint64
+	news     atomic.Uint64
+}
+
+func (pool *TypedPool[T]) init() {
+	pool.initOnce.Do(func() {
+		if pool.Pool == nil {
+			pool.Pool = &sync.Pool{}
+		}
+	})
+}
+
+// Get acquires a object from the pool, or creates one by New if the pool is empty.
+func (pool *TypedPool[T]) Get() T {
+	pool.init()
+
+	pool.gets.Add(1)
+	x := pool.Pool.Get()
+	if x == nil {
+		pool.news.Add(1)
+		if pool.New != nil {
+			return pool.New()
+		}
+		var zero T
+		return zero
+	}
+	return x.(T)
+}
+
+// Put resets and releases a object.
+func (pool *TypedPool[T]) Put(x T) {
+	pool.puts.Add(1)
+	pool.release(x)
+}
+
+// release resets and releases a object without counting it.
+func (pool *TypedPool[T]) release(x T) {
+	pool.init()
+
+	if pool.Reset != nil {
+		pool.Reset(x)
+	}
+	pool.Pool.Put(x)
+}
+
+// Prewarm creates count objects by New and releases them in advance.
+// The objects are not counted by Stats.
+// It does nothing if New is nil.
+func (pool *TypedPool[T]) Prewarm(count int) {
+	if pool.New == nil {
+		return
+	}
+	for i := 0; i < count; i++ {
+		pool.release(pool.New())
+	}
+}
+
+// Stats returns a snapshot of the counters of the pool.
+func (pool *TypedPool[T]) Stats() PoolStats {
+	return PoolStats{
+		Gets: pool.gets.Load(),
+		Puts: pool.puts.Load(),
+		News: pool.news.Load(),
+	}
+}
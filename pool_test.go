@@ -0,0 +1,90 @@
+package bytespool
+
+import "testing"
+
+type sampleObject struct {
+	value int
+}
+
+func TestTypedPool(t *testing.T) {
+	var news int
+	pool := TypedPool[*sampleObject]{
+		Pool: NewBoundedPool(1),
+		New: func() *sampleObject {
+			news++
+			return &sampleObject{}
+		},
+		Reset: func(object *sampleObject) {
+			object.value = 0
+		},
+	}
+
+	object1 := pool.Get()
+	object1.value = 1
+	pool.Put(object1)
+
+	object2 := pool.Get()
+	if object2 != object1 {
+		t.Fatal("object should be reused")
+		return
+	}
+	if object2.value != 0 {
+		t.Fatalf("object should be reset, have: %d", object2.value)
+		return
+	}
+	if news != 1 {
+		t.Fatalf("news error, want: %d, have: %d", 1, news)
+	}
+}
+
+func TestTypedPoolWithoutNew(t *testing.T) {
+	var pool TypedPool[*sampleObject]
+	if object := pool.Get(); object != nil {
+		t.Fatalf("object should be nil, have: %v", object)
+	}
+}
+
+func TestTypedPoolPrewarm(t *testing.T) {
+	var news int
+	pool := TypedPool[*sampleObject]{
+		Pool: NewBoundedPool(2),
+		New: func() *sampleObject {
+			news++
+			return &sampleObject{}
+		},
+	}
+
+	pool.Prewarm(2)
+	pool.Get()
+	pool.Get()
+	if news != 2 {
+		t.Fatalf("news error, want: %d, have: %d", 2, news)
+	}
+}
+
+func TestTypedPoolStats(t *testing.T) {
+	pool := TypedPool[*sampleObject]{
+		Pool: NewBoundedPool(2),
+		New: func() *sampleObject {
+			return &sampleObject{}
+		},
+	}
+	pool.Prewarm(1)
+
+	object1 := pool.Get()
+	object2 := pool.Get()
+	pool.Put(object1)
+
+	stats := pool.Stats()
+	if stats.Gets != 2 || stats.Puts != 1 || stats.News != 1 {
+		t.Fatalf("stats error, have: %+v", stats)
+		return
+	}
+	if stats.Hits() != 1 {
+		t.Fatalf("hits error, want: %d, have: %d", 1, stats.Hits())
+	}
+	if stats.Outstanding() != 1 {
+		t.Fatalf("outstanding error, want: %d, have: %d", 1, stats.Outstanding())
+	}
+	pool.Put(object2)
+}
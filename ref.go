@@ -0,0 +1,64 @@
+package bytespool
+
+import "sync/atomic"
+
+// GetRef is a quick method for DefaultBytesPool.GetRef.
+func GetRef(length int) *Ref {
+	return DefaultBytesPool.GetRef(length)
+}
+
+// Ref is a reference-counted bytes of a pool.
+// The bytes is released to the pool after the last reference is released.
+// A Ref is not reused, so a excessive Retain or Release is detected.
+type Ref struct {
+	bytes []byte
+	pool  *BytesPool
+	refs  atomic.Int32
+}
+
+// GetRef acquires bytes like Get, and wraps it in a Ref that holds one reference.
+func (pool *BytesPool) GetRef(length int) *Ref {
+	ref := &Ref{
+		bytes: pool.Get(length),
+		pool:  pool,
+	}
+	ref.refs.Store(1)
+	return ref
+}
+
+// Bytes returns the bytes.
+// The bytes must not be used after the reference is released.
+func (ref *Ref) Bytes() []byte {
+	return ref.bytes
+}
+
+// Refs returns the number of the references.
+func (ref *Ref) Refs() int {
+	return int(ref.refs.Load())
+}
+
+// Retain adds a reference, and returns the ref.
+// It panics if the bytes was already released.
+func (ref *Ref) Retain() *Ref {
+	for {
+		refs := ref.refs.Load()
+		if refs <= 0 {
+			panic("bytespool: retain a released Ref")
+		}
+		if ref.refs.CompareAndSwap(refs, refs+1) {
+			return ref
+		}
+	}
+}
+
+// Release releases a reference, and releases the bytes to the pool if it is the last one.
+// It panics if it is called more than the references.
+func (ref *Ref) Release() {
+	refs := ref.refs.Add(-1)
+	if refs < 0 {
+		panic("bytespool: Ref released too many times")
+	}
+	if refs == 0 {
+		ref.pool.Put(ref.bytes)
+	}
+}
@@ -0,0 +1,61 @@
+package bytespool
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRef(t *testing.T) {
+	pool := BytesPool{Debug: DebugTrackPuts}
+
+	ref := pool.GetRef(100)
+	if len(ref.Bytes()) != 100 {
+		t.Fatalf("bytes length error, want: %d, have: %d", 100, len(ref.Bytes()))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(ref *Ref) {
+			defer wg.Done()
+			defer ref.Release()
+			_ = ref.Bytes()[0]
+		}(ref.Retain())
+	}
+	wg.Wait()
+
+	if ref.Refs() != 1 {
+		t.Fatalf("refs error, want: %d, have: %d", 1, ref.Refs())
+		return
+	}
+	if puts := pool.Stats().Classes[pool.getIndex(100)].Puts; puts != 0 {
+		t.Fatalf("puts error, want: %d, have: %d", 0, puts)
+		return
+	}
+
+	ref.Release()
+	if puts := pool.Stats().Classes[pool.getIndex(100)].Puts; puts != 1 {
+		t.Fatalf("puts error, want: %d, have: %d", 1, puts)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("excessive release should panic")
+		}
+	}()
+	ref.Release()
+}
+
+func TestRefRetainReleased(t *testing.T) {
+	ref := GetRef(10)
+	ref.Release()
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("retaining a released ref should panic")
+		}
+	}()
+	ref.Retain()
+}
@@ -0,0 +1,272 @@
+package bytespool
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrRingFull is returned by a non-blocking RingBuffer.Write if the ring buffer is full,
+	// and by RingBuffer.Peek if n exceeds the capacity.
+	ErrRingFull = errors.New("bytespool: ring buffer is full")
+
+	// ErrRingEmpty is returned by the non-blocking reads of RingBuffer if the ring buffer has not enough bytes.
+	ErrRingEmpty = errors.New("bytespool: ring buffer is empty")
+
+	// ErrRingClosed is returned by the writes of RingBuffer after it is closed.
+	ErrRingClosed = errors.New("bytespool: ring buffer is closed")
+)
+
+// NewRingBuffer is a quick method for DefaultBytesPool.NewRingBuffer.
+func NewRingBuffer(capacity int, blocking bool) *RingBuffer {
+	return DefaultBytesPool.NewRingBuffer(capacity, blocking)
+}
+
+// RingBuffer is a fixed capacity circular buffer of the bytes of a pool.
+// In the blocking mode, Write waits for the free space, and the reads wait for the data.
+// In the non-blocking mode, they return ErrRingFull or ErrRingEmpty instead.
+// Close releases the bytes to the pool, the reads return io.EOF and the writes return ErrRingClosed after it.
+// It is safe for concurrent use.
+type RingBuffer struct {
+	// bytes is nil after Close.
+	bytes    []byte
+	capacity int
+	pool     *BytesPool
+	blocking bool
+
+	mutex sync.Mutex
+	// readable is signaled when bytes are written or the ring buffer is closed.
+	readable sync.Cond
+	// writable is signaled when bytes are read or the ring buffer is closed.
+	writable sync.Cond
+
+	start  int
+	length int
+	closed bool
+}
+
+// NewRingBuffer creates a ring buffer of the capacity, with the bytes acquired from the pool.
+func (pool *BytesPool) NewRingBuffer(capacity int, blocking bool) *RingBuffer {
+	if capacity <= 0 {
+		panic("capacity of the ring buffer must be greater than 0")
+	}
+
+	ring := &RingBuffer{
+		bytes:    pool.Get(capacity),
+		capacity: capacity,
+		pool:     pool,
+		blocking: blocking,
+	}
+	ring.readable.L = &ring.mutex
+	ring.writable.L = &ring.mutex
+	return ring
+}
+
+// Cap returns the capacity of the ring buffer.
+func (ring *RingBuffer) Cap() int {
+	return ring.capacity
+}
+
+// Len returns the number of the unread bytes.
+func (ring *RingBuffer) Len() int {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	return ring.length
+}
+
+// put copies p to the free space, and returns the number of the copied bytes.
+func (ring *RingBuffer) put(p []byte) int {
+	n := ring.capacity - ring.length
+	if n > len(p) {
+		n = len(p)
+	}
+	end := (ring.start + ring.length) % ring.capacity
+	m := copy(ring.bytes[end:], p[:n])
+	copy(ring.bytes, p[m:n])
+	ring.length += n
+	return n
+}
+
+// take copies the unread bytes to p, and returns the number of the copied bytes.
+func (ring *RingBuffer) take(p []byte) int {
+	n := ring.length
+	if n > len(p) {
+		n = len(p)
+	}
+	m := copy(p[:n], ring.bytes[ring.start:])
+	copy(p[m:n], ring.bytes)
+	ring.skip(n)
+	return n
+}
+
+// skip advances the ring buffer by n unread bytes.
+func (ring *RingBuffer) skip(n int) {
+	ring.length -= n
+	if ring.length == 0 {
+		ring.start = 0
+	} else {
+		ring.start = (ring.start + n) % ring.capacity
+	}
+}
+
+// linearize moves the unread bytes to the start of the bytes, so they are contiguous.
+func (ring *RingBuffer) linearize() {
+	reverse(ring.bytes[:ring.start])
+	reverse(ring.bytes[ring.start:])
+	reverse(ring.bytes)
+	ring.start = 0
+}
+
+func reverse(bytes []byte) {
+	for i, j := 0, len(bytes)-1; i < j; i, j = i+1, j-1 {
+		bytes[i], bytes[j] = bytes[j], bytes[i]
+	}
+}
+
+// Write writes p to the ring buffer.
+// In the blocking mode, it waits until all of p is written or the ring buffer is closed.
+// In the non-blocking mode, it writes as many bytes as fit, and returns ErrRingFull if not all of p is written.
+func (ring *RingBuffer) Write(p []byte) (int, error) {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	var nWrote int
+	for {
+		if ring.closed {
+			return nWrote, ErrRingClosed
+		}
+
+		n := ring.put(p[nWrote:])
+		nWrote += n
+		if n > 0 {
+			ring.readable.Broadcast()
+		}
+		if nWrote == len(p) {
+			return nWrote, nil
+		}
+
+		if !ring.blocking {
+			return nWrote, ErrRingFull
+		}
+		ring.writable.Wait()
+	}
+}
+
+// waitReadable waits until n bytes are unread in the blocking mode.
+// It returns io.EOF if the ring buffer is closed, or ErrRingEmpty if the bytes are not enough in the non-blocking mode.
+func (ring *RingBuffer) waitReadable(n int) error {
+	for {
+		if ring.closed {
+			return io.EOF
+		}
+		if ring.length >= n {
+			return nil
+		}
+		if !ring.blocking {
+			return ErrRingEmpty
+		}
+		ring.readable.Wait()
+	}
+}
+
+// Read reads up to len(p) bytes from the ring buffer.
+// In the blocking mode, it waits until any bytes are written.
+// In the non-blocking mode, it returns ErrRingEmpty if the ring buffer is empty.
+func (ring *RingBuffer) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	err := ring.waitReadable(1)
+	if err != nil {
+		return 0, err
+	}
+
+	n := ring.take(p)
+	ring.writable.Broadcast()
+	return n, nil
+}
+
+// Peek returns the next n bytes without advancing the ring buffer.
+// The bytes are valid only until the next read or Close.
+// In the blocking mode, it waits until n bytes are written.
+// In the non-blocking mode, it returns the unread bytes and ErrRingEmpty if they are fewer than n.
+// It returns ErrRingFull if n exceeds the capacity.
+func (ring *RingBuffer) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		panic("bytespool.RingBuffer.Peek: negative count")
+	}
+	if n > ring.capacity {
+		return nil, ErrRingFull
+	}
+
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	err := ring.waitReadable(n)
+	if err == io.EOF {
+		return nil, err
+	}
+	if n > ring.length {
+		n = ring.length
+	}
+	if ring.start+n > ring.capacity {
+		// the peeked bytes wrap around.
+		ring.linearize()
+	}
+	return ring.bytes[ring.start : ring.start+n], err
+}
+
+// Discard skips the next n bytes, and returns the number of the discarded bytes.
+// In the blocking mode, it waits until n bytes are discarded.
+// In the non-blocking mode, it discards the unread bytes and returns ErrRingEmpty if they are fewer than n.
+func (ring *RingBuffer) Discard(n int) (int, error) {
+	if n < 0 {
+		panic("bytespool.RingBuffer.Discard: negative count")
+	}
+
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	var nDiscarded int
+	for nDiscarded < n {
+		err := ring.waitReadable(1)
+		if err != nil {
+			return nDiscarded, err
+		}
+
+		m := n - nDiscarded
+		if m > ring.length {
+			m = ring.length
+		}
+		ring.skip(m)
+		nDiscarded += m
+		ring.writable.Broadcast()
+	}
+	return nDiscarded, nil
+}
+
+// Close releases the bytes to the pool, and wakes the waiting reads and writes.
+// It returns ErrRingClosed if the ring buffer was already closed.
+func (ring *RingBuffer) Close() error {
+	ring.mutex.Lock()
+	defer ring.mutex.Unlock()
+
+	if ring.closed {
+		return ErrRingClosed
+	}
+	ring.closed = true
+	ring.start = 0
+	ring.length = 0
+	ring.pool.Put(ring.bytes)
+	ring.bytes = nil
+
+	ring.readable.Broadcast()
+	ring.writable.Broadcast()
+	return nil
+}
@@ -0,0 +1,191 @@
+package bytespool
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestRingBufferNonBlocking(t *testing.T) {
+	pool := &BytesPool{}
+	ring := pool.NewRingBuffer(8, false)
+
+	n, err := ring.Write([]byte("0123456789"))
+	if n != 8 || err != ErrRingFull {
+		t.Fatalf("write error, want: %d, %v, have: %d, %v", 8, ErrRingFull, n, err)
+		return
+	}
+
+	buff := make([]byte, 5)
+	n, err = ring.Read(buff)
+	if n != 5 || err != nil || string(buff) != "01234" {
+		t.Fatalf("read error, have: %d, %v, %q", n, err, buff)
+		return
+	}
+
+	// wrap around
+	ring.Write([]byte("89a"))
+	peeked, err := ring.Peek(6)
+	if err != nil || string(peeked) != "56789a" {
+		t.Fatalf("peek error, have: %q, %v", peeked, err)
+		return
+	}
+	peeked, err = ring.Peek(7)
+	if err != ErrRingEmpty || string(peeked) != "56789a" {
+		t.Fatalf("peek error, have: %q, %v", peeked, err)
+		return
+	}
+	if _, err = ring.Peek(9); err != ErrRingFull {
+		t.Fatalf("peek error, want: %v, have: %v", ErrRingFull, err)
+		return
+	}
+
+	n, err = ring.Discard(2)
+	if n != 2 || err != nil {
+		t.Fatalf("discard error, have: %d, %v", n, err)
+		return
+	}
+	n, err = ring.Discard(10)
+	if n != 4 || err != ErrRingEmpty {
+		t.Fatalf("discard error, have: %d, %v", n, err)
+		return
+	}
+	if _, err = ring.Read(buff); err != ErrRingEmpty {
+		t.Fatalf("read error, want: %v, have: %v", ErrRingEmpty, err)
+		return
+	}
+
+	if err = ring.Close(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if _, err = ring.Read(buff); err != io.EOF {
+		t.Fatalf("read error, want: %v, have: %v", io.EOF, err)
+		return
+	}
+	if _, err = ring.Write(buff); err != ErrRingClosed {
+		t.Fatalf("write error, want: %v, have: %v", ErrRingClosed, err)
+		return
+	}
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("outstanding bytes error, want: %d, have: %d", 0, outstanding)
+	}
+}
+
+func TestRingBufferBlocking(t *testing.T) {
+	ring := NewRingBuffer(100, true)
+	defer ring.Close()
+
+	rand.Seed(time.Now().Unix())
+	data := make([]byte, 1024*1024)
+	rand.Read(data)
+
+	go func() {
+		for p := data; len(p) > 0; {
+			n := rand.Intn(300) + 1
+			if n > len(p) {
+				n = len(p)
+			}
+			ring.Write(p[:n])
+			p = p[n:]
+		}
+	}()
+
+	var have []byte
+	for len(have) < len(data) {
+		switch rand.Intn(3) {
+		case 0:
+			buff := make([]byte, rand.Intn(200)+1)
+			n, err := ring.Read(buff)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			have = append(have, buff[:n]...)
+		case 1:
+			n := rand.Intn(100) + 1
+			if n > len(data)-len(have) {
+				n = len(data) - len(have)
+			}
+			peeked, err := ring.Peek(n)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			have = append(have, peeked...)
+			ring.Discard(len(peeked))
+		case 2:
+			n := rand.Intn(50) + 1
+			if n > len(data)-len(have) {
+				n = len(data) - len(have)
+			}
+			nDiscarded, err := ring.Discard(n)
+			if err != nil {
+				t.Fatal(err)
+				return
+			}
+			have = append(have, data[len(have):len(have)+nDiscarded]...)
+		}
+	}
+	if !bytes.Equal(have, data) {
+		t.Fatal("read data missmatch")
+	}
+}
+
+func TestRingBufferCloseWakes(t *testing.T) {
+	empty := NewRingBuffer(10, true)
+	full := NewRingBuffer(10, true)
+	full.Write(make([]byte, 10))
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := empty.Read(make([]byte, 1))
+		errs <- err
+	}()
+	go func() {
+		_, err := full.Write(make([]byte, 1))
+		errs <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	empty.Close()
+	full.Close()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != io.EOF && err != ErrRingClosed {
+				t.Fatalf("error error, have: %v", err)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the waiting operations should be woken by Close")
+			return
+		}
+	}
+}
+
+func TestRingBufferCloseConcurrently(t *testing.T) {
+	ring := NewRingBuffer(10, false)
+	ring.Write([]byte("01234"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			ring.Cap()
+			ring.Peek(5)
+		}
+	}()
+	ring.Close()
+	<-done
+
+	if ring.Cap() != 10 {
+		t.Fatalf("cap error, want: %d, have: %d", 10, ring.Cap())
+		return
+	}
+	if _, err := ring.Peek(5); err != io.EOF {
+		t.Fatalf("peek error, want: %v, have: %v", io.EOF, err)
+	}
+}
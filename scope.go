@@ -0,0 +1,135 @@
+package bytespool
+
+import (
+	"context"
+	"sync"
+)
+
+// Scope tracks the bytes and buffers acquired from a pool,
+// and releases them all at once.
+// It implements SizedBytesPool, so it can be the BytesPool of a Buffer.
+// It is safe for concurrent use, and reusable after Release.
+type Scope struct {
+	// BytesPool is the pool of the bytes of the scope.
+	// default is DefaultBytesPool.
+	BytesPool *BytesPool
+
+	mutex   sync.Mutex
+	bytes   map[uintptr][]byte
+	buffers []*Buffer
+}
+
+// NewScope creates a scope of the pool.
+func (pool *BytesPool) NewScope() *Scope {
+	return &Scope{BytesPool: pool}
+}
+
+func (scope *Scope) pool() *BytesPool {
+	if scope.BytesPool == nil {
+		return DefaultBytesPool
+	}
+	return scope.BytesPool
+}
+
+// Get acquires bytes from the pool, which will be released by Release.
+func (scope *Scope) Get(length int) []byte {
+	return scope.track(scope.pool().Get(length))
+}
+
+// GetAligned acquires aligned bytes from the pool, which will be released by Release.
+func (scope *Scope) GetAligned(length, alignment int) []byte {
+	return scope.track(scope.pool().GetAligned(length, alignment))
+}
+
+// GetContext acquires bytes from the pool like BytesPool.GetContext, which will be released by Release.
+func (scope *Scope) GetContext(ctx context.Context, length int) ([]byte, error) {
+	bytes, err := scope.pool().GetContext(ctx, length)
+	if err != nil {
+		return nil, err
+	}
+	return scope.track(bytes), nil
+}
+
+// GetAlignedContext acquires aligned bytes from the pool like BytesPool.GetAlignedContext, which will be released by Release.
+func (scope *Scope) GetAlignedContext(ctx context.Context, length, alignment int) ([]byte, error) {
+	bytes, err := scope.pool().GetAlignedContext(ctx, length, alignment)
+	if err != nil {
+		return nil, err
+	}
+	return scope.track(bytes), nil
+}
+
+func (scope *Scope) track(bytes []byte) []byte {
+	if cap(bytes) == 0 {
+		return bytes
+	}
+
+	scope.mutex.Lock()
+	defer scope.mutex.Unlock()
+
+	if scope.bytes == nil {
+		scope.bytes = make(map[uintptr][]byte)
+	}
+	scope.bytes[arrayPointer(bytes)] = bytes
+	return bytes
+}
+
+// Put releases the bytes before the scope is released.
+func (scope *Scope) Put(bytes []byte) {
+	scope.mutex.Lock()
+	delete(scope.bytes, arrayPointer(bytes))
+	scope.mutex.Unlock()
+
+	scope.pool().Put(bytes)
+}
+
+// GetBuffer acquires a buffer which grows with the bytes of the scope.
+// The buffer will be released by Release, it must not be released by PutBuffer.
+func (scope *Scope) GetBuffer() *Buffer {
+	buffer := GetBuffer()
+	buffer.BytesPool = scope
+
+	scope.mutex.Lock()
+	defer scope.mutex.Unlock()
+
+	scope.buffers = append(scope.buffers, buffer)
+	return buffer
+}
+
+// PutBuffer releases the buffer before the scope is released.
+func (scope *Scope) PutBuffer(buffer *Buffer) {
+	scope.mutex.Lock()
+	for idx, b := range scope.buffers {
+		if b == buffer {
+			scope.buffers = append(scope.buffers[:idx], scope.buffers[idx+1:]...)
+			break
+		}
+	}
+	scope.mutex.Unlock()
+
+	PutBuffer(buffer)
+}
+
+// Release releases all the bytes and buffers acquired from the scope.
+func (scope *Scope) Release() {
+	scope.mutex.Lock()
+	bytess := scope.bytes
+	buffers := scope.buffers
+	scope.bytes = nil
+	scope.buffers = nil
+	scope.mutex.Unlock()
+
+	for _, buffer := range buffers {
+		// the bytes of the buffer are tracked by the scope.
+		if buffer.bytesPool == scope {
+			buffer.bytes = nil
+			buffer.bytesPool = nil
+		}
+		PutBuffer(buffer)
+	}
+
+	pool := scope.pool()
+	for _, bytes := range bytess {
+		pool.Put(bytes)
+	}
+}
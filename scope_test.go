@@ -0,0 +1,44 @@
+package bytespool
+
+import "testing"
+
+func TestScope(t *testing.T) {
+	var tracker LeakTracker
+	pool := BytesPool{LeakTracker: &tracker}
+	scope := pool.NewScope()
+
+	_ = scope.Get(100)
+	bytes := scope.Get(2000)
+	scope.Put(bytes)
+	_ = scope.Get(0)
+
+	buffer := scope.GetBuffer()
+	for i := 0; i < 10; i++ {
+		buffer.Write(make([]byte, 1000))
+	}
+	if buffer.Len() != 10000 {
+		t.Fatalf("buffer length error, want: %d, have: %d", 10000, buffer.Len())
+		return
+	}
+	if tracker.Len() != 2 {
+		t.Fatalf("outstanding length error, want: %d, have: %d", 2, tracker.Len())
+		return
+	}
+
+	scope.Release()
+	if tracker.Len() != 0 {
+		t.Fatalf("outstanding length error, want: %d, have: %d", 0, tracker.Len())
+		return
+	}
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("outstanding bytes error, want: %d, have: %d", 0, outstanding)
+		return
+	}
+
+	// reuse
+	_ = scope.Get(100)
+	scope.Release()
+	if outstanding := pool.Stats().OutstandingBytes(); outstanding != 0 {
+		t.Fatalf("outstanding bytes error, want: %d, have: %d", 0, outstanding)
+	}
+}
@@ -0,0 +1,136 @@
+package bytespool
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ShardedPoolFactory returns a factory producing the ShardedPool of each size class,
+// with a shard for each P, localSize items at most in each shard,
+// and overflowSize items at most in the shared overflow list.
+func ShardedPoolFactory(localSize, overflowSize int) SizedBytesPoolFactory {
+	return func(size int) Pool {
+		return NewShardedPool(runtime.GOMAXPROCS(0), localSize, overflowSize)
+	}
+}
+
+type poolShardInternal struct {
+	mutex sync.Mutex
+	items []interface{}
+}
+
+// poolShard is a local cache of ShardedPool.
+type poolShard struct {
+	poolShardInternal
+
+	// pad to 128 bytes like sync.Pool, the shards are not aligned to cache lines,
+	// but the fields of the adjacent shards are a cache line apart at least.
+	_ [128 - unsafe.Sizeof(poolShardInternal{})%128]byte
+}
+
+// ShardedPool is a pool with local caches and a shared overflow list, like tcmalloc.
+// A goroutine works on a shard of its own mostly, a full shard spills half of the items to the overflow list,
+// and a empty shard is refilled from it. A contended shard is skipped for the overflow list.
+// Go does not expose the current P, so the shard is chosen by a index cached in a sync.Pool,
+// which is kept per P mostly. A new index is assigned round-robin.
+// The items survive garbage collections.
+// Get returns nil if the pool is empty, and Put drops the item if the pool is full.
+type ShardedPool struct {
+	shards    []poolShard
+	localSize int
+	overflow  *BoundedPool
+
+	// hints caches the *int indexes of the shards.
+	hints sync.Pool
+	next  atomic.Uint32
+}
+
+// NewShardedPool creates a ShardedPool with the number of shards,
+// localSize items at most in each shard, and overflowSize items at most in the shared overflow list.
+func NewShardedPool(shards, localSize, overflowSize int) *ShardedPool {
+	if shards <= 0 {
+		panic("number of shards must be greater than 0")
+	}
+	if localSize < 0 {
+		panic("local size must be greater than or equal to 0")
+	}
+
+	pool := &ShardedPool{
+		shards:    make([]poolShard, shards),
+		localSize: localSize,
+		overflow:  NewBoundedPool(overflowSize),
+	}
+	for idx := range pool.shards {
+		pool.shards[idx].items = make([]interface{}, 0, localSize)
+	}
+	return pool
+}
+
+// shard returns the shard of the current P mostly, and its index,
+// which must be released by pool.hints.Put after the shard is used.
+func (pool *ShardedPool) shard() (*poolShard, *int) {
+	hint, _ := pool.hints.Get().(*int)
+	if hint == nil {
+		hint = new(int)
+		*hint = int((pool.next.Add(1) - 1) % uint32(len(pool.shards)))
+	}
+	return &pool.shards[*hint], hint
+}
+
+// Get returns a idle item, or nil if the pool is empty.
+func (pool *ShardedPool) Get() interface{} {
+	shard, hint := pool.shard()
+	defer pool.hints.Put(hint)
+	if !shard.mutex.TryLock() {
+		return pool.overflow.Get()
+	}
+	defer shard.mutex.Unlock()
+
+	if len(shard.items) == 0 {
+		// refill half of the shard
+		for len(shard.items) < pool.localSize/2 {
+			x := pool.overflow.Get()
+			if x == nil {
+				break
+			}
+			shard.items = append(shard.items, x)
+		}
+		if len(shard.items) == 0 {
+			return pool.overflow.Get()
+		}
+	}
+
+	x := shard.items[len(shard.items)-1]
+	shard.items[len(shard.items)-1] = nil
+	shard.items = shard.items[:len(shard.items)-1]
+	return x
+}
+
+// Put keeps the item, or drops it if the pool is full.
+func (pool *ShardedPool) Put(x interface{}) {
+	shard, hint := pool.shard()
+	defer pool.hints.Put(hint)
+	if !shard.mutex.TryLock() {
+		pool.overflow.Put(x)
+		return
+	}
+	defer shard.mutex.Unlock()
+
+	if len(shard.items) >= pool.localSize {
+		if pool.localSize == 0 {
+			pool.overflow.Put(x)
+			return
+		}
+
+		// spill half of the shard
+		half := len(shard.items) / 2
+		for idx, item := range shard.items[half:] {
+			pool.overflow.Put(item)
+			shard.items[half+idx] = nil
+		}
+		shard.items = shard.items[:half]
+	}
+	shard.items = append(shard.items, x)
+}
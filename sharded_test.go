@@ -0,0 +1,79 @@
+package bytespool
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestShardedPool(t *testing.T) {
+	pool := NewShardedPool(1, 2, 2)
+	if x := pool.Get(); x != nil {
+		t.Fatalf("empty pool should return nil, have: %v", x)
+		return
+	}
+
+	for i := 0; i < 5; i++ {
+		pool.Put(i) // the 5th is dropped
+	}
+	runtime.GC()
+
+	var count int
+	for pool.Get() != nil {
+		count++
+	}
+	if count != 4 {
+		t.Fatalf("count error, want: %d, have: %d", 4, count)
+	}
+}
+
+func TestShardedPoolConcurrency(t *testing.T) {
+	pool := BytesPool{SizedPoolFactory: ShardedPoolFactory(8, 64)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10000; j++ {
+				bytes := pool.Get(j%4096 + 1)
+				pool.Put(bytes)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if rate := pool.Stats().HitRate(); rate < 0.5 {
+		t.Fatalf("hit rate error, want: >= %f, have: %f", 0.5, rate)
+	}
+}
+
+func TestShardedPoolDistinctShards(t *testing.T) {
+	pool := NewShardedPool(4, 1024, 0)
+
+	// the goroutines hold their hints together, none of them gets a cached hint of the others,
+	// so each of them gets a shard of its own.
+	var acquired, wg sync.WaitGroup
+	shards := make([]*poolShard, 4)
+	for i := range shards {
+		acquired.Add(1)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			shard, hint := pool.shard()
+			shards[i] = shard
+			acquired.Done()
+			acquired.Wait()
+			pool.hints.Put(hint)
+		}(i)
+	}
+	wg.Wait()
+
+	used := make(map[*poolShard]bool)
+	for _, shard := range shards {
+		used[shard] = true
+	}
+	if len(used) != len(shards) {
+		t.Fatalf("the concurrent holders should get distinct shards, want: %d, have: %d", len(shards), len(used))
+	}
+}
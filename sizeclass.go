@@ -0,0 +1,114 @@
+package bytespool
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultSizeClasses is the default value of BytesPool.SizeClasses.
+// The capacities are powers of two up to 1KiB, then 1KiB steps up to 1MiB.
+var DefaultSizeClasses = defaultSizeClasses()
+
+func defaultSizeClasses() []int {
+	capacities := make([]int, 0, indexLength)
+	for idx := 0; idx <= littleIndexUpper; idx++ {
+		capacities = append(capacities, 1<<idx)
+	}
+	for capacity := littleCapacityUpper * 2; capacity <= largeCapacityUpper; capacity += 1024 {
+		capacities = append(capacities, capacity)
+	}
+	return capacities
+}
+
+// GeometricSizeClasses returns the capacities grown from min by factor, up to max.
+// Each capacity is at least one greater than the previous, the last one is max.
+func GeometricSizeClasses(min, max int, factor float64) []int {
+	if min <= 0 || max < min {
+		panic(fmt.Sprintf("invalid capacity range, min: %d, max: %d", min, max))
+	}
+	if factor <= 1 {
+		panic("factor must be greater than 1")
+	}
+
+	capacities := []int{min}
+	for capacity := min; capacity < max; {
+		next := int(math.Ceil(float64(capacity) * factor))
+		if next <= capacity {
+			next = capacity + 1
+		}
+		if next > max {
+			next = max
+		}
+		capacities = append(capacities, next)
+		capacity = next
+	}
+	return capacities
+}
+
+// largeSizeClasses returns the power of two capacities greater than max, up to ceiling.
+func largeSizeClasses(max, ceiling int) []int {
+	var capacities []int
+	capacity := 1
+	for capacity <= max {
+		capacity *= 2
+	}
+	for ; capacity > 0 && capacity <= ceiling; capacity *= 2 {
+		capacities = append(capacities, capacity)
+	}
+	return capacities
+}
+
+// sizeClasses is a ascending table of the capacities of size classes.
+type sizeClasses []int
+
+func newSizeClasses(capacities []int) sizeClasses {
+	if len(capacities) == 0 {
+		panic("size classes must not be empty")
+	}
+	for idx, capacity := range capacities {
+		if capacity <= 0 {
+			panic(fmt.Sprintf("capacity must be greater than 0, have: %d, index: %d", capacity, idx))
+		}
+		if idx > 0 && capacity <= capacities[idx-1] {
+			panic(fmt.Sprintf("capacities must be ascending, have: %d after %d, index: %d", capacity, capacities[idx-1], idx))
+		}
+	}
+
+	classes := make(sizeClasses, len(capacities))
+	copy(classes, capacities)
+	return classes
+}
+
+// maxCapacity returns the capacity of the largest size class.
+func (classes sizeClasses) maxCapacity() int {
+	return classes[len(classes)-1]
+}
+
+// getCapacity returns the capacity of the size class.
+func (classes sizeClasses) getCapacity(idx int) int {
+	if idx < 0 || idx >= len(classes) {
+		panic(fmt.Sprintf("index must be smaller than %d", len(classes)))
+	}
+	return classes[idx]
+}
+
+// getIndex returns the index of the smallest size class which can hold length bytes.
+func (classes sizeClasses) getIndex(length int) int {
+	if length > classes.maxCapacity() {
+		panic(fmt.Sprintf("length must be smaller or equal than %d", classes.maxCapacity()))
+	}
+	return sort.SearchInts(classes, length)
+}
+
+// findIndex returns the index of the size class that capacity is exactly equal to.
+func (classes sizeClasses) findIndex(capacity int) (int, bool) {
+	if capacity <= 0 || capacity > classes.maxCapacity() {
+		return 0, false
+	}
+	idx := sort.SearchInts(classes, capacity)
+	if classes[idx] != capacity {
+		return 0, false
+	}
+	return idx, true
+}
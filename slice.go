@@ -0,0 +1,150 @@
+package bytespool
+
+import (
+	"reflect"
+	"sync"
+)
+
+// defaultSlicePools is the default SlicePool of each element type.
+var defaultSlicePools sync.Map
+
+// DefaultSlicePool returns the default SlicePool of the element type T.
+func DefaultSlicePool[T any]() *SlicePool[T] {
+	key := reflect.TypeOf((*T)(nil)).Elem()
+	if pool, ok := defaultSlicePools.Load(key); ok {
+		return pool.(*SlicePool[T])
+	}
+	pool, _ := defaultSlicePools.LoadOrStore(key, &SlicePool[T]{})
+	return pool.(*SlicePool[T])
+}
+
+// GetSlice is a quick method for DefaultSlicePool[T]().Get.
+func GetSlice[T any](length int) []T {
+	return DefaultSlicePool[T]().Get(length)
+}
+
+// PutSlice is a quick method for DefaultSlicePool[T]().Put.
+func PutSlice[T any](slice []T) {
+	DefaultSlicePool[T]().Put(slice)
+}
+
+// SlicePool is a pool of the slices of T,
+// which reuses the slices by the same size classes as BytesPool.
+type SlicePool[T any] struct {
+	// SizedPoolFactory is a factory for producing the pool of a size class.
+	// The produced pool should return nil if it is empty,
+	// it must not allocate the bytes by itself.
+	// default produces a sync.Pool that returns nil if it is empty.
+	SizedPoolFactory SizedBytesPoolFactory
+
+	// SizeClasses is the ascending capacities of the size classes.
+	// It takes effect at the first use of the pool.
+	// default is DefaultSizeClasses.
+	SizeClasses []int
+
+	// ZeroOnPut zeroes the elements up to the capacity when the slice is released,
+	// even if T contains no pointers.
+	// The elements that contain pointers are always zeroed.
+	// default is false.
+	ZeroOnPut bool
+
+	initOnce    sync.Once
+	table       sizeClasses
+	pools       []Pool
+	newPoolMutx sync.Mutex
+	pointers    bool
+}
+
+func (pool *SlicePool[T]) init() {
+	pool.initOnce.Do(func() {
+		capacities := pool.SizeClasses
+		if capacities == nil {
+			capacities = DefaultSizeClasses
+		}
+		pool.table = newSizeClasses(capacities)
+		pool.pools = make([]Pool, len(pool.table))
+		pool.pointers = hasPointers(reflect.TypeOf((*T)(nil)).Elem())
+	})
+}
+
+// hasPointers reports whether the values of the type contain pointers.
+func hasPointers(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return false
+	case reflect.Array:
+		return typ.Len() > 0 && hasPointers(typ.Elem())
+	case reflect.Struct:
+		for idx := 0; idx < typ.NumField(); idx++ {
+			if hasPointers(typ.Field(idx).Type) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func (pool *SlicePool[T]) getPool(idx int) Pool {
+	p := pool.pools[idx]
+	if p == nil {
+		pool.newPoolMutx.Lock()
+		defer pool.newPoolMutx.Unlock()
+
+		p = pool.pools[idx]
+		if p == nil {
+			factory := pool.SizedPoolFactory
+			if factory == nil {
+				factory = emptySizedPoolFactory
+			}
+			p = factory(pool.table.getCapacity(idx))
+			pool.pools[idx] = p
+		}
+	}
+	return p
+}
+
+// Get acquire a slice with a len of length and a capacity of at least length.
+func (pool *SlicePool[T]) Get(length int) []T {
+	pool.init()
+
+	if length < 0 {
+		panic("length must be greater than 0")
+	} else if length == 0 {
+		return []T{}
+	} else if length > pool.table.maxCapacity() {
+		return make([]T, length)
+	}
+
+	idx := pool.table.getIndex(length)
+	x := pool.getPool(idx).Get()
+	if x == nil {
+		return make([]T, length, pool.table.getCapacity(idx))
+	}
+	return x.([]T)[:length]
+}
+
+// Put releases a slice.
+// If T contains pointers, or ZeroOnPut is set, all the elements up to the capacity are zeroed,
+// so the slice does not keep the referenced objects alive.
+// The slice that capacity is not of any size class is dropped.
+func (pool *SlicePool[T]) Put(slice []T) {
+	pool.init()
+
+	idx, found := pool.table.findIndex(cap(slice))
+	if !found {
+		return
+	}
+
+	if pool.pointers || pool.ZeroOnPut {
+		slice = slice[:cap(slice)]
+		var zero T
+		for i := range slice {
+			slice[i] = zero
+		}
+	}
+	pool.getPool(idx).Put(slice[:0])
+}
@@ -0,0 +1,98 @@
+package bytespool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSlicePool(t *testing.T) {
+	pool := SlicePool[*sampleObject]{
+		SizedPoolFactory: func(size int) Pool { return NewBoundedPool(1) },
+	}
+
+	slice1 := pool.Get(100)
+	if len(slice1) != 100 || cap(slice1) != 128 {
+		t.Fatalf("slice error, want: len %d cap %d, have: len %d cap %d", 100, 128, len(slice1), cap(slice1))
+		return
+	}
+	slice1[0] = &sampleObject{value: 1}
+	pool.Put(slice1)
+
+	slice2 := pool.Get(120)
+	if &slice2[0] != &slice1[0] {
+		t.Fatal("slice should be reused")
+		return
+	}
+	if slice2[0] != nil {
+		t.Fatal("slice should be zeroed")
+		return
+	}
+
+	if slice := pool.Get(largeCapacityUpper + 1); len(slice) != largeCapacityUpper+1 {
+		t.Fatalf("slice length error, want: %d, have: %d", largeCapacityUpper+1, len(slice))
+	}
+}
+
+func TestGetSlice(t *testing.T) {
+	floats := GetSlice[float64](10)
+	if len(floats) != 10 {
+		t.Fatalf("slice length error, want: %d, have: %d", 10, len(floats))
+		return
+	}
+	PutSlice(floats)
+
+	if DefaultSlicePool[float64]() != DefaultSlicePool[float64]() {
+		t.Fatal("default pool should be unique")
+		return
+	}
+	strings := GetSlice[string](3)
+	if cap(strings) != 4 {
+		t.Fatalf("slice capacity error, want: %d, have: %d", 4, cap(strings))
+	}
+	PutSlice(strings)
+}
+
+func TestSlicePoolZeroing(t *testing.T) {
+	for _, zeroOnPut := range []bool{false, true} {
+		pool := SlicePool[int32]{
+			SizedPoolFactory: func(size int) Pool { return NewBoundedPool(1) },
+			ZeroOnPut:        zeroOnPut,
+		}
+
+		slice := pool.Get(10)
+		slice[0] = 1
+		pool.Put(slice)
+
+		slice = pool.Get(10)
+		if zeroed := slice[0] == 0; zeroed != zeroOnPut {
+			t.Fatalf("zeroing error, want: %t, have: %t", zeroOnPut, zeroed)
+			return
+		}
+	}
+}
+
+func TestHasPointers(t *testing.T) {
+	type plain struct {
+		a int
+		b [2]float64
+	}
+	type pointer struct {
+		a int
+		b [1]string
+	}
+	for _, c := range []struct {
+		value interface{}
+		want  bool
+	}{
+		{int32(0), false},
+		{plain{}, false},
+		{[0]*int{}, false},
+		{pointer{}, true},
+		{"", true},
+		{&sampleObject{}, true},
+	} {
+		if have := hasPointers(reflect.TypeOf(c.value)); have != c.want {
+			t.Fatalf("%T: want: %t, have: %t", c.value, c.want, have)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package bytespool
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// maxCounterStripes is the maximum number of the stripes of the counters of BytesPool.
+const maxCounterStripes = 16
+
+// classCounters is the counters of a size class in a stripe.
+type classCounters struct {
+	gets atomic.Uint64
+	puts atomic.Uint64
+	news atomic.Uint64
+
+	// idle is the approximate number of the idle bytes.
+	// It is reset when the pool is found empty,
+	// as the pool may drop the idle bytes silently.
+	idle atomic.Int64
+}
+
+// counters is the counters of the size classes of BytesPool,
+// striped so the Ps do not contend for them, and Stats sums the stripes.
+// The bytes are counted in the stripe chosen by the hash of their address,
+// so the Ps working on the different bytes count in the different stripes mostly,
+// and the idle bytes is counted down in the stripe it was counted up.
+type counters struct {
+	// stripes is allocated at the first use of each stripe.
+	stripes []atomic.Pointer[[]classCounters]
+	length  int
+	// shift is the shift of the hash to a stripe index.
+	shift uint
+}
+
+func (counters *counters) init(length int) {
+	var bits uint
+	for 1<<bits < runtime.GOMAXPROCS(0) && 1<<bits < maxCounterStripes {
+		bits++
+	}
+	counters.stripes = make([]atomic.Pointer[[]classCounters], 1<<bits)
+	counters.length = length
+	counters.shift = 64 - bits
+}
+
+// stripeOf returns the stripe of the bytes at the address.
+func (counters *counters) stripeOf(ptr uintptr) []classCounters {
+	// Fibonacci hashing spreads the addresses of the adjacent bytes.
+	hash := uint64(ptr) * 0x9e3779b97f4a7c15
+	return counters.stripe(int(hash >> counters.shift))
+}
+
+// stripe returns the stripe of the index, and allocates it at the first use.
+func (counters *counters) stripe(idx int) []classCounters {
+	stripe := counters.stripes[idx].Load()
+	if stripe == nil {
+		// pad both ends, so the stripe shares no cache line with the other allocations.
+		pad := int(128 / unsafe.Sizeof(classCounters{}))
+		classes := make([]classCounters, counters.length+2*pad)[pad : pad+counters.length]
+		counters.stripes[idx].CompareAndSwap(nil, &classes)
+		stripe = counters.stripes[idx].Load()
+	}
+	return *stripe
+}
+
+// load sums the counters of the size class in all stripes.
+func (counters *counters) load(idx int) (gets, puts, news uint64, idle int64) {
+	for s := range counters.stripes {
+		if stripe := counters.stripes[s].Load(); stripe != nil {
+			class := &(*stripe)[idx]
+			gets += class.gets.Load()
+			puts += class.puts.Load()
+			news += class.news.Load()
+			idle += class.idle.Load()
+		}
+	}
+	return gets, puts, news, idle
+}
+
+// idle returns the approximate number of the idle bytes of the size class.
+func (counters *counters) idle(idx int) int64 {
+	var idle int64
+	for s := range counters.stripes {
+		if stripe := counters.stripes[s].Load(); stripe != nil {
+			idle += (*stripe)[idx].idle.Load()
+		}
+	}
+	return idle
+}
+
+// takeIdle counts down a idle bytes of the size class, from the stripe first if it is not nil,
+// and from the other stripes if the stripe counts none.
+// It returns false if no idle bytes is counted.
+func (counters *counters) takeIdle(stripe []classCounters, idx int) bool {
+	if stripe != nil && takeIdle(&stripe[idx].idle) {
+		return true
+	}
+	for s := range counters.stripes {
+		if other := counters.stripes[s].Load(); other != nil && takeIdle(&(*other)[idx].idle) {
+			return true
+		}
+	}
+	return false
+}
+
+// takeIdle counts down a idle bytes, returns false if no idle bytes is counted.
+func takeIdle(idle *atomic.Int64) bool {
+	for {
+		n := idle.Load()
+		if n <= 0 {
+			return false
+		}
+		if idle.CompareAndSwap(n, n-1) {
+			return true
+		}
+	}
+}
+
+// resetIdle resets the idle count of the size class in all stripes,
+// and returns the count before.
+func (counters *counters) resetIdle(idx int) int64 {
+	var idle int64
+	for s := range counters.stripes {
+		if stripe := counters.stripes[s].Load(); stripe != nil {
+			idle += (*stripe)[idx].idle.Swap(0)
+		}
+	}
+	return idle
+}
+
+// ClassStats represents the counters of a size class of BytesPool.
+type ClassStats struct {
+	// Capacity is the capacity of the bytes of the size class.
+	Capacity int
+
+	// Gets is the number of the bytes acquired from the size class.
+	Gets uint64
+
+	// Puts is the number of the bytes released to the size class.
+	Puts uint64
+
+	// News is the number of the bytes newly allocated because the size class was empty.
+	News uint64
+
+	// Idle is the approximate number of the idle bytes of the size class.
+	Idle int64
+}
+
+// Hits returns the number of the bytes reused by the size class.
+func (stats ClassStats) Hits() uint64 {
+	if stats.News > stats.Gets {
+		return 0
+	}
+	return stats.Gets - stats.News
+}
+
+// HitRate returns the ratio of the reused bytes to the acquired bytes.
+// If no bytes acquired, returns 0.
+func (stats ClassStats) HitRate() float64 {
+	if stats.Gets == 0 {
+		return 0
+	}
+	return float64(stats.Hits()) / float64(stats.Gets)
+}
+
+// OutstandingBytes returns the capacity of the bytes acquired and not yet released.
+func (stats ClassStats) OutstandingBytes() int64 {
+	if stats.Puts >= stats.Gets {
+		return 0
+	}
+	return int64(stats.Gets-stats.Puts) * int64(stats.Capacity)
+}
+
+// IdleBytes returns the approximate capacity of the idle bytes.
+func (stats ClassStats) IdleBytes() int64 {
+	return stats.Idle * int64(stats.Capacity)
+}
+
+// PoolStats represents the counters of TypedPool.
+type PoolStats struct {
+	// Gets is the number of the objects acquired from the pool.
+	Gets uint64
+
+	// Puts is the number of the objects released to the pool.
+	Puts uint64
+
+	// News is the number of the objects created because the pool was empty.
+	News uint64
+}
+
+// Hits returns the number of the objects reused by the pool.
+func (stats PoolStats) Hits() uint64 {
+	if stats.News > stats.Gets {
+		return 0
+	}
+	return stats.Gets - stats.News
+}
+
+// HitRate returns the ratio of the reused objects to the acquired objects.
+// If no object acquired, returns 0.
+func (stats PoolStats) HitRate() float64 {
+	if stats.Gets == 0 {
+		return 0
+	}
+	return float64(stats.Hits()) / float64(stats.Gets)
+}
+
+// Outstanding returns the number of the objects acquired and not yet released.
+func (stats PoolStats) Outstanding() int64 {
+	if stats.Puts >= stats.Gets {
+		return 0
+	}
+	return int64(stats.Gets - stats.Puts)
+}
+
+// Stats represents the counters of BytesPool.
+type Stats struct {
+	// Classes is the counters of each size class, ordered by capacity.
+	Classes []ClassStats
+
+	// OversizeGets is the number of the bytes allocated without pooling
+	// because the length exceeds the largest size class.
+	OversizeGets uint64
+}
+
+// Gets returns the number of the bytes acquired from all size classes.
+func (stats Stats) Gets() uint64 {
+	var gets uint64
+	for _, class := range stats.Classes {
+		gets += class.Gets
+	}
+	return gets
+}
+
+// HitRate returns the ratio of the reused bytes to the acquired bytes of all size classes.
+func (stats Stats) HitRate() float64 {
+	var gets, hits uint64
+	for _, class := range stats.Classes {
+		gets += class.Gets
+		hits += class.Hits()
+	}
+	if gets == 0 {
+		return 0
+	}
+	return float64(hits) / float64(gets)
+}
+
+// OutstandingBytes returns the capacity of the bytes acquired from all size classes and not yet released.
+func (stats Stats) OutstandingBytes() int64 {
+	var outstanding int64
+	for _, class := range stats.Classes {
+		outstanding += class.OutstandingBytes()
+	}
+	return outstanding
+}
+
+// IdleBytes returns the approximate capacity of the idle bytes of all size classes.
+func (stats Stats) IdleBytes() int64 {
+	var idle int64
+	for _, class := range stats.Classes {
+		idle += class.IdleBytes()
+	}
+	return idle
+}
+
+// Stats returns a snapshot of the counters of the pool.
+// The counters are striped and updated atomically, so it is cheap to leave them on.
+func (pool *BytesPool) Stats() Stats {
+	pool.init()
+
+	stats := Stats{
+		Classes:      make([]ClassStats, len(pool.classes)),
+		OversizeGets: pool.oversizeGets.Load(),
+	}
+	for idx := range pool.classes {
+		gets, puts, news, idle := pool.counters.load(idx)
+		stats.Classes[idx] = ClassStats{
+			Capacity: pool.getCapacity(idx),
+			Gets:     gets,
+			Puts:     puts,
+			News:     news,
+			Idle:     idle,
+		}
+	}
+	return stats
+}
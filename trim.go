@@ -0,0 +1,144 @@
+package bytespool
+
+import (
+	"context"
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+var (
+	// DefaultTrimInterval is the default value of Trimmer.Interval.
+	DefaultTrimInterval = time.Second
+
+	// DefaultTrimPressure is the default ratio of the memory limit,
+	// above which Trimmer considers the process under memory pressure.
+	DefaultTrimPressure = 0.9
+)
+
+// Trim releases the idle bytes, the largest size classes first,
+// until the idle capacity is not more than targetBytes.
+// It returns the capacity of the released bytes.
+// The idle capacity is approximate, see Stats.
+func (pool *BytesPool) Trim(targetBytes int64) int64 {
+	pool.init()
+
+	var idleBytes int64
+	for idx := range pool.classes {
+		idleBytes += pool.counters.idle(idx) * int64(pool.classes[idx].capacity)
+	}
+
+	var released int64
+	for idx := len(pool.classes) - 1; idx >= 0 && idleBytes > targetBytes; idx-- {
+		class := &pool.classes[idx]
+		if pool.counters.idle(idx) <= 0 || class.pool == nil {
+			continue
+		}
+
+		for idleBytes > targetBytes && pool.counters.takeIdle(nil, idx) {
+			idleBytes -= int64(class.capacity)
+			if pool.MaxIdleBytes > 0 {
+				pool.idleBytes.Add(-int64(class.capacity))
+			}
+
+			x := class.pool.Get()
+			if x == nil {
+				// the pool is empty, whatever the count is.
+				idle := pool.counters.resetIdle(idx)
+				idleBytes -= idle * int64(class.capacity)
+				if pool.MaxIdleBytes > 0 {
+					pool.idleBytes.Add(-idle * int64(class.capacity))
+				}
+				break
+			}
+
+			discard(class.pool, x.([]byte))
+			released += int64(class.capacity)
+		}
+	}
+	return released
+}
+
+// Trimmer trims the idle bytes of a pool when the process is under memory pressure.
+type Trimmer struct {
+	// Pool is the pool to trim.
+	// default is DefaultBytesPool.
+	Pool *BytesPool
+
+	// Interval is the interval of the memory checks.
+	// default is DefaultTrimInterval.
+	Interval time.Duration
+
+	// TargetBytes is the idle capacity kept after trimming.
+	// default is 0, all idle bytes are released.
+	TargetBytes int64
+
+	// SoftLimit is the memory usage above which the process is under memory pressure.
+	// The memory usage is measured like the memory limit of the runtime,
+	// the total memory mapped by the runtime minus the memory released to the OS.
+	// default is DefaultTrimPressure of the limit set by debug.SetMemoryLimit,
+	// no trimming if the memory limit is not set.
+	SoftLimit int64
+}
+
+// Run checks the memory usage periodically until the context is done,
+// and trims the pool if the process is under memory pressure.
+func (trimmer *Trimmer) Run(ctx context.Context) {
+	interval := trimmer.Interval
+	if interval == 0 {
+		interval = DefaultTrimInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			trimmer.TrimIfPressured()
+		}
+	}
+}
+
+// TrimIfPressured trims the pool if the process is under memory pressure.
+// It returns the capacity of the released bytes.
+func (trimmer *Trimmer) TrimIfPressured() int64 {
+	softLimit := trimmer.SoftLimit
+	if softLimit == 0 {
+		limit := debug.SetMemoryLimit(-1)
+		if limit == math.MaxInt64 {
+			return 0
+		}
+		softLimit = int64(float64(limit) * DefaultTrimPressure)
+	}
+	if memoryUsage() < softLimit {
+		return 0
+	}
+
+	pool := trimmer.Pool
+	if pool == nil {
+		pool = DefaultBytesPool
+	}
+	return pool.Trim(trimmer.TargetBytes)
+}
+
+// memoryUsage returns the memory usage of the process,
+// measured like the memory limit of the runtime.
+func memoryUsage() int64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+
+	var values [2]uint64
+	for idx, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			values[idx] = sample.Value.Uint64()
+		}
+	}
+	return int64(values[0] - values[1])
+}
@@ -0,0 +1,47 @@
+package bytespool
+
+import "testing"
+
+func TestTrim(t *testing.T) {
+	pool := BytesPool{SizedPoolFactory: BoundedPoolFactory(10, 0)}
+	pool.Prewarm(map[int]int{
+		100:  2,
+		2000: 2,
+	})
+
+	released := pool.Trim(2*128 + 2048)
+	if released != 2048 {
+		t.Fatalf("released bytes error, want: %d, have: %d", 2048, released)
+		return
+	}
+	if idle := pool.Stats().IdleBytes(); idle != 2*128+2048 {
+		t.Fatalf("idle bytes error, want: %d, have: %d", 2*128+2048, idle)
+		return
+	}
+
+	released = pool.Trim(0)
+	if released != 2*128+2048 {
+		t.Fatalf("released bytes error, want: %d, have: %d", 2*128+2048, released)
+		return
+	}
+	pool.Get(100)
+	if news := pool.Stats().Classes[pool.getIndex(100)].News; news != 1 {
+		t.Fatalf("news error, want: %d, have: %d", 1, news)
+	}
+}
+
+func TestTrimmer(t *testing.T) {
+	pool := BytesPool{SizedPoolFactory: BoundedPoolFactory(10, 0)}
+	pool.Prewarm(map[int]int{100: 2})
+
+	trimmer := Trimmer{Pool: &pool, SoftLimit: 1<<63 - 1}
+	if released := trimmer.TrimIfPressured(); released != 0 {
+		t.Fatalf("released bytes error, want: %d, have: %d", 0, released)
+		return
+	}
+
+	trimmer.SoftLimit = 1
+	if released := trimmer.TrimIfPressured(); released != 2*128 {
+		t.Fatalf("released bytes error, want: %d, have: %d", 2*128, released)
+	}
+}